@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var atlasCmd *cobra.Command
+
+var atlasTrim bool
+var atlasRotate bool
+var atlasFormat string
+
+var atlasFormats = []string{"hash", "array"}
+
+const validAtlasFormatsMessage = "Valid formats are: \"hash\" (TexturePacker JSON hash, frames keyed by filename) and \"array\" (TexturePacker JSON array)."
+
+// drawAtlas draws packed sprites, rotated and extruded as they were packed
+func drawAtlas(sprites []i.Sprite, width, height, extrusion int) *image.NRGBA {
+	atlas := imaging.New(width, height, BgColor)
+	for _, sprite := range sprites {
+		spriteImage := sprite.Image
+		if sprite.Rotated {
+			spriteImage = imaging.Rotate270(spriteImage)
+		}
+		if extrusion > 0 {
+			spriteImage = extrudeTile(spriteImage, extrusion, extrudeMode, BgColor)
+		}
+		min := sprite.Position.Sub(image.Pt(extrusion, extrusion))
+		rect := image.Rectangle{min, min.Add(spriteImage.Bounds().Size())}
+		draw.Draw(atlas, rect, spriteImage, spriteImage.Bounds().Min, draw.Src)
+	}
+	return atlas
+}
+
+func init() {
+
+	atlasCmd = &cobra.Command{
+		Use:   "atlas <directory|filename>...",
+		Short: "Pack sprites of any size into an atlas.",
+		Long:  "The atlas command packs images of any size into a texture atlas with the MaxRects algorithm, trying several atlas widths and keeping the one with the least area. Directories are expanded to the images in them. Sprites can have their transparent border trimmed and can be rotated 90 degrees clockwise to pack tighter. The atlas has the out-margin around it, and the out-spacing and any extrusion between sprites. The frames are written to a TexturePacker compatible json file next to the atlas, named by filename.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				fmt.Fprintln(os.Stderr, "At least one arg required: <directory|filename>...")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool atlas --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if err := i.ValidatePixelValue(outMargin); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid out-margin: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidatePixelValue(outSpacing); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid out-spacing: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidatePixelValue(packExtrusion); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid extrusion: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(extrudeMode, extrudeModes); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid mode: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(atlasFormat, atlasFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid format: %s\n", err.Error())
+				os.Exit(1)
+			}
+			validateSheetLayout()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filenames, err := packFilenames(args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error finding images: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if len(filenames) == 0 {
+				fmt.Fprintln(os.Stderr, "Error: no images to pack")
+				os.Exit(1)
+			}
+
+			sprites := make([]i.Sprite, len(filenames))
+			names := map[string]string{}
+			for j, filename := range filenames {
+				name := filepath.Base(filename)
+				if other, ok := names[name]; ok {
+					fmt.Fprintf(os.Stderr, "Error: %s and %s would have the same frame name\n", other, filename)
+					os.Exit(1)
+				}
+				names[name] = filename
+				sprites[j] = i.NewSprite(name, i.Open(filename, Verbose), atlasTrim)
+			}
+
+			config := i.AtlasConfig{
+				Margin:    outMargin,
+				Spacing:   outSpacing,
+				Extrusion: packExtrusion,
+				Rotate:    atlasRotate,
+				MaxWidth:  sheetLayout.MaxWidth,
+				Align:     sheetLayout.Align,
+				Pot:       sheetLayout.Pot,
+			}
+			width, height, err := i.PackSprites(sprites, config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error packing sprites: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			if Verbose {
+				used, rotated := 0, 0
+				for _, sprite := range sprites {
+					used += sprite.Trim.Dx() * sprite.Trim.Dy()
+					if sprite.Rotated {
+						rotated++
+					}
+				}
+				fmt.Printf("Packed %d sprites, %d rotated, into %dx%d: %.1f%% used\n",
+					len(sprites), rotated, width, height, 100*float64(used)/float64(width*height))
+			}
+
+			atlasImage := drawAtlas(sprites, width, height, packExtrusion)
+			i.Save(atlasImage, Output, Verbose)
+
+			framesFilename := tilesetMetadataFilename(".json")
+			if Verbose {
+				fmt.Printf("Saving frames to %s\n", framesFilename)
+			}
+			exitOnSaveError(i.WriteTexturePackerJSON(framesFilename, Output, sprites, width, height, atlasFormat == "array", Version))
+		},
+	}
+	atlasCmd.Flags().BoolVar(&atlasTrim, "trim", false, "trim the fully transparent border of each sprite (default false)")
+	atlasCmd.Flags().BoolVar(&atlasRotate, "rotate", false, "allow sprites to be rotated 90 degrees clockwise to pack tighter (default false)")
+	atlasCmd.Flags().IntVar(&outMargin, "out-margin", 0, "atlas margin in pixels (default 0)")
+	atlasCmd.Flags().IntVar(&outSpacing, "out-spacing", 0, "spacing between sprites in pixels, outside any extrusion (default 0)")
+	atlasCmd.Flags().IntVar(&packExtrusion, "extrusion", 0, "extrusion thickness in pixels around each sprite (default 0)")
+	atlasCmd.Flags().StringVar(&extrudeMode, "mode", "clamp", fmt.Sprintf("how to fill the extrusion. %s", validExtrudeModesMessage))
+	atlasCmd.Flags().IntVar(&sheetLayout.MaxWidth, "max-width", 0, "limit the atlas to this width in pixels (default 0, no limit)")
+	atlasCmd.Flags().BoolVar(&sheetLayout.Pot, "pot", false, "pad the atlas to power of two dimensions (default false)")
+	atlasCmd.Flags().IntVar(&sheetLayout.Align, "align", 0, "pad the atlas to dimensions that are a multiple of this many pixels (default 0, no padding)")
+	atlasCmd.Flags().StringVarP(&atlasFormat, "format", "f", "hash", fmt.Sprintf("frame data format. %s", validAtlasFormatsMessage))
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestPackSprites(t *testing.T) {
+
+	// Sprites of several sizes, each with a transparent border and a
+	// gradient so that a misplaced or misrotated pixel shows
+	sizes := []image.Point{{40, 10}, {12, 30}, {16, 16}, {5, 5}, {25, 8}, {9, 21}, {3, 3}}
+	sources := []*image.NRGBA{}
+	for j, size := range sizes {
+		img := image.NewNRGBA(image.Rect(0, 0, size.X+4, size.Y+2))
+		for y := 1; y <= size.Y; y++ {
+			for x := 2; x < size.X+2; x++ {
+				img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 6), G: uint8(y * 8), B: uint8(j * 30), A: 255})
+			}
+		}
+		sources = append(sources, img)
+	}
+
+	configs := []i.AtlasConfig{
+		{},
+		{Margin: 1, Spacing: 2, Extrusion: 1, Rotate: true},
+		{Rotate: true, MaxWidth: 48, Pot: true},
+		{MaxWidth: 100, Pot: true},
+	}
+	for _, config := range configs {
+		t.Run(fmt.Sprintf("%+v", config), func(t *testing.T) {
+			sprites := make([]i.Sprite, len(sources))
+			for j, source := range sources {
+				sprites[j] = i.NewSprite(fmt.Sprintf("%d.png", j), source, true)
+				if sprites[j].Trim != image.Rect(2, 1, sizes[j].X+2, sizes[j].Y+1) {
+					t.Fatalf("sprite %d: expected trim to the opaque pixels, got %v", j, sprites[j].Trim)
+				}
+			}
+
+			width, height, err := i.PackSprites(sprites, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.MaxWidth > 0 && width > config.MaxWidth {
+				t.Errorf("expected a width of at most %d, got %d", config.MaxWidth, width)
+			}
+			if config.Pot && (width&(width-1) != 0 || height&(height-1) != 0) {
+				t.Errorf("expected power of two dimensions, got %dx%d", width, height)
+			}
+
+			BgColor = color.Transparent
+			extrudeMode = "clamp"
+			atlas := drawAtlas(sprites, width, height, config.Extrusion)
+			gutter := config.Spacing + (2 * config.Extrusion)
+			for j, sprite := range sprites {
+				w, h := sprite.Size()
+				rect := image.Rect(sprite.Position.X, sprite.Position.Y, sprite.Position.X+w, sprite.Position.Y+h)
+				if !rect.Inset(-config.Extrusion).In(image.Rect(config.Margin, config.Margin, width, height)) {
+					t.Errorf("sprite %d at %v is outside the atlas", j, rect)
+				}
+				for k, other := range sprites[:j] {
+					ow, oh := other.Size()
+					otherRect := image.Rect(other.Position.X, other.Position.Y, other.Position.X+ow, other.Position.Y+oh)
+					if rect.Inset(-gutter).Overlaps(otherRect) {
+						t.Errorf("sprite %d at %v is too close to sprite %d at %v", j, rect, k, otherRect)
+					}
+				}
+
+				expected := sprite.Image
+				if sprite.Rotated {
+					expected = imaging.Rotate270(expected)
+				}
+				if hashNrgba(atlas.SubImage(rect).(*image.NRGBA)) != hashNrgba(expected) {
+					t.Errorf("sprite %d: atlas pixels don't match the sprite", j)
+				}
+			}
+		})
+	}
+}
+
+func TestPackSpritesPaddedMaxWidth(t *testing.T) {
+
+	// Without rotation a 40 pixel wide sprite pads to 64, past the max width
+	sprites := []i.Sprite{i.NewSprite("wide.png", image.NewNRGBA(image.Rect(0, 0, 40, 10)), false)}
+	if width, _, err := i.PackSprites(sprites, i.AtlasConfig{MaxWidth: 48, Pot: true}); err == nil {
+		t.Errorf("expected an error, got an atlas %d pixels wide", width)
+	}
+}
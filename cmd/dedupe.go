@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var dedupeCmd *cobra.Command
+
+func init() {
+
+	dedupeCmd = &cobra.Command{
+		Use:   "dedupe <filename>",
+		Short: "Remove duplicate tiles from a tileset.",
+		Long:  "The dedupe command removes repeated tiles from a tileset, keeping the first of each in order. The tileset is read with the size, margin and spacing flags and written with the same layout. With transform, tiles that are flips or rotations of an earlier tile are removed too. A remap table named after the tileset gives the new index of each old tile and the flips that draw the old tile from it (packed as in flags sidecars), so maps can be fixed up.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "One arg required: <filename>")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool dedupe --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if err := i.ValidateChoice(remapFormat, remapFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid remap: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(metadataFormat, metadataFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid metadata: %s\n", err.Error())
+				os.Exit(1)
+			}
+			validateSheetLayout()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filename := args[0]
+
+			img := i.Open(filename, Verbose)
+			applyAutoLayout(img)
+			if err := tc.ReadImage(img); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading tileset: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			var transformations []string
+			if transform {
+				transformations = CreateTransformations()
+				if tc.TileWidth != tc.TileHeight {
+					transformations = flipOnlyTransformations(transformations)
+				}
+			}
+			parseConfig := i.ParseConfig{TileWidth: tc.TileWidth, TileHeight: tc.TileHeight, Order: "first-seen"}
+			frequencyTiles, occurrences := computeFreq(nil, [][]*image.NRGBA{tc.TileImages}, transformations, parseConfig)
+
+			fmt.Printf("Removed %d duplicate tiles, %d remain\n", len(tc.TileImages)-len(frequencyTiles), len(frequencyTiles))
+
+			outTc := tc
+			outTc.Color = BgColor
+			outTc.TileImages = make([]*image.NRGBA, len(frequencyTiles))
+			for j, frequencyTile := range frequencyTiles {
+				outTc.TileImages[j] = frequencyTile.Image
+			}
+			reflow(&outTc, Verbose)
+
+			tilesetImage := outTc.ToImage()
+			i.Save(tilesetImage, Output, Verbose)
+
+			if metadataFormat != "none" {
+				writeTilesetMetadata(metadataFormat, outTc, Verbose)
+			}
+			writeRemaps([]string{filename}, occurrences, Verbose)
+		},
+	}
+	dedupeCmd.Flags().BoolVarP(&transform, "transform", "t", false, "also remove tiles that are flips or rotations of an earlier tile. Non-square tiles are only flipped and rotated by 180 degrees (default false)")
+	dedupeCmd.Flags().StringVar(&remapFormat, "remap", "json", fmt.Sprintf("remap table format. %s", validRemapFormatsMessage))
+	dedupeCmd.Flags().StringVar(&metadataFormat, "metadata", "none", fmt.Sprintf("tileset metadata format to write alongside the tileset. %s", validMetadataFormatsMessage))
+	addSheetLayoutFlags(dedupeCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestDedupe(t *testing.T) {
+
+	// Each tile followed by a copy and a rotated copy of it
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	tileset.ReadImage(i.Open("../fixtures/test_02.png", false))
+	tiles := []*image.NRGBA{}
+	for _, tileImage := range tileset.TileImages {
+		tiles = append(tiles, tileImage, imaging.Clone(tileImage), imaging.Rotate90(tileImage))
+	}
+
+	parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize, Order: "first-seen"}
+	frequencyTiles, occurrences := computeFreq(nil, [][]*image.NRGBA{tiles}, CreateTransformations(), parseConfig)
+
+	// Kept tiles are in the order they were first seen
+	remap := i.NewRemap("tileset.png", "deduped.png", occurrences[0])
+	next := 0
+	for j, entry := range remap.Tiles {
+		if entry.New > next {
+			t.Errorf("tile %d: expected an index of at most %d, got %d", j, next, entry.New)
+		}
+		if entry.New == next {
+			next++
+		}
+		drawn := i.FlipFromBits(uint32(entry.Flags) << 29).Apply(frequencyTiles[entry.New].Image)
+		if hashNrgba(drawn) != hashNrgba(tiles[j]) {
+			t.Errorf("tile %d: tile %d with flags %d doesn't draw the original", j, entry.New, entry.Flags)
+		}
+	}
+	// The 9 tiles of test_02 are unique up to transformation
+	if len(frequencyTiles) != 9 || next != 9 {
+		t.Errorf("expected 9 tiles, got %d", len(frequencyTiles))
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var detectCmd *cobra.Command
+
+var detectSizes []int
+var detectTop int
+
+var defaultDetectSizes = []int{8, 16, 24, 32, 48, 64}
+
+type gridCandidate struct {
+	parseConfig i.ParseConfig
+	total       int
+	unique      int
+	uncovered   int
+	score       int
+}
+
+// scoreGrid crops the images with a grid and counts the tiles. The score is
+// the number of pixels it takes to describe the images with that grid: the
+// unique tiles, one per map cell, and the pixels the grid doesn't cover.
+// Lower is better.
+func scoreGrid(imgs []*image.NRGBA, parseConfig i.ParseConfig) gridCandidate {
+	candidate := gridCandidate{parseConfig: parseConfig}
+	hashes := map[string]bool{}
+	for _, img := range imgs {
+		crops := parseConfig.CropTiles(img)
+		for _, crop := range crops {
+			hashes[hashNrgba(crop)] = true
+		}
+		candidate.total += len(crops)
+		candidate.uncovered += (img.Bounds().Dx() * img.Bounds().Dy()) - (len(crops) * parseConfig.TileWidth * parseConfig.TileHeight)
+	}
+	candidate.unique = len(hashes)
+	candidate.score = (candidate.unique * parseConfig.TileWidth * parseConfig.TileHeight) + candidate.total + candidate.uncovered
+	return candidate
+}
+
+// detectGrid tries every square tile size with every offset smaller than it
+// and returns the candidates best first
+func detectGrid(imgs []*image.NRGBA, sizes []int) []gridCandidate {
+	minWidth, minHeight := imgs[0].Bounds().Dx(), imgs[0].Bounds().Dy()
+	for _, img := range imgs[1:] {
+		if img.Bounds().Dx() < minWidth {
+			minWidth = img.Bounds().Dx()
+		}
+		if img.Bounds().Dy() < minHeight {
+			minHeight = img.Bounds().Dy()
+		}
+	}
+
+	candidates := []gridCandidate{}
+	for _, size := range sizes {
+		if size > minWidth || size > minHeight {
+			continue
+		}
+		for yOffset := 0; yOffset < size && yOffset+size <= minHeight; yOffset++ {
+			for xOffset := 0; xOffset < size && xOffset+size <= minWidth; xOffset++ {
+				parseConfig := i.ParseConfig{TileWidth: size, TileHeight: size, XOffset: xOffset, YOffset: yOffset}
+				candidates = append(candidates, scoreGrid(imgs, parseConfig))
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score < candidates[b].score
+	})
+	return candidates
+}
+
+func outputDetectTable(candidates []gridCandidate) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Rank", "Size", "Offset", "Tiles", "Unique", "Uncovered Pixels", "Score"})
+	for j, candidate := range candidates {
+		t.AppendRow(table.Row{
+			fmt.Sprintf("%d", j+1),
+			fmt.Sprintf("%dx%d", candidate.parseConfig.TileWidth, candidate.parseConfig.TileHeight),
+			fmt.Sprintf("%dx%d", candidate.parseConfig.XOffset, candidate.parseConfig.YOffset),
+			fmt.Sprintf("%d", candidate.total),
+			fmt.Sprintf("%d", candidate.unique),
+			fmt.Sprintf("%d", candidate.uncovered),
+			fmt.Sprintf("%d", candidate.score),
+		})
+	}
+	t.Render()
+}
+
+func init() {
+
+	detectCmd = &cobra.Command{
+		Use:   "detect <filename>",
+		Short: "Detect the tile grid of an image.",
+		Long:  "The detect command searches tile sizes, and every offset smaller than the tile size, for the grid that best describes an image. Each grid is scored by the pixels needed to store its unique tiles plus one per tile in the map plus the pixels the grid leaves uncovered, so grids with few unique tiles rank first. The best configurations are listed with their tile counts.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "One arg required: <filename>")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool detect --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			for _, size := range detectSizes {
+				if err := i.ValidatePositivePixelValue(size); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid sizes: %s\n", err.Error())
+					os.Exit(1)
+				}
+			}
+			if detectTop < 1 {
+				fmt.Fprintln(os.Stderr, "Invalid top: value must be at least 1")
+				os.Exit(1)
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filename := args[0]
+
+			img := i.Open(filename, Verbose)
+
+			candidates := detectGrid([]*image.NRGBA{img}, detectSizes)
+			if len(candidates) == 0 {
+				fmt.Fprintln(os.Stderr, "Error: the image is smaller than every tile size")
+				os.Exit(1)
+			}
+			if Verbose {
+				fmt.Printf("Scored %d grids\n", len(candidates))
+			}
+			if len(candidates) > detectTop {
+				candidates = candidates[:detectTop]
+			}
+			outputDetectTable(candidates)
+		},
+	}
+	detectCmd.Flags().IntSliceVar(&detectSizes, "sizes", defaultDetectSizes, "tile sizes in pixels to try")
+	detectCmd.Flags().IntVar(&detectTop, "top", 5, "number of configurations to list (default 5)")
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"image"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+func TestDetectGrid(t *testing.T) {
+
+	// Four tiles of noise, so that no part of a tile repeats on its own,
+	// scattered over a 6x6 grid offset into a larger canvas
+	random := rand.New(rand.NewSource(1))
+	tiles := make([]*image.NRGBA, 4)
+	for j := range tiles {
+		tiles[j] = image.NewNRGBA(image.Rect(0, 0, testTileSize, testTileSize))
+		random.Read(tiles[j].Pix)
+		for k := 3; k < len(tiles[j].Pix); k += 4 {
+			tiles[j].Pix[k] = 255
+		}
+	}
+	offset := image.Pt(5, 3)
+	img := image.NewNRGBA(image.Rect(0, 0, (6*testTileSize)+offset.X+2, (6*testTileSize)+offset.Y+2))
+	for row := 0; row < 6; row++ {
+		for column := 0; column < 6; column++ {
+			min := offset.Add(image.Pt(column*testTileSize, row*testTileSize))
+			tile := tiles[random.Intn(len(tiles))]
+			draw.Draw(img, image.Rectangle{min, min.Add(tile.Bounds().Size())}, tile, image.Point{}, draw.Src)
+		}
+	}
+
+	candidates := detectGrid([]*image.NRGBA{img}, defaultDetectSizes)
+	best := candidates[0].parseConfig
+	if best.TileWidth != testTileSize || best.TileHeight != testTileSize {
+		t.Errorf("expected a tile size of %d, got %dx%d", testTileSize, best.TileWidth, best.TileHeight)
+	}
+	if best.XOffset != offset.X || best.YOffset != offset.Y {
+		t.Errorf("expected offset %v, got %dx%d", offset, best.XOffset, best.YOffset)
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var mapFormats = []string{"none", "tmx", "tmj", "ldtk", "csv", "bin"}
+var metadataFormats = []string{"none", "tsx", "tsj"}
+
+const validMapFormatsMessage = "Valid formats are: \"none\", \"tmx\" (Tiled map with an external tsx tileset), \"tmj\" (Tiled JSON map with an external tsj tileset), \"ldtk\" (LDtk project), \"csv\" (tileset indices with a flags.csv sidecar) and \"bin\" (little-endian uint16 tileset indices with a flags.bin sidecar)."
+const validMetadataFormatsMessage = "Valid formats are: \"none\", \"tsx\" (Tiled XML tileset) and \"tsj\" (Tiled JSON tileset)."
+
+// Maps are named after the image they were parsed from and saved in the
+// same directory as the output tileset
+func mapFilename(filename, extension string) string {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return filepath.Join(filepath.Dir(Output), base+extension)
+}
+
+// Tileset metadata is saved next to the output tileset with the same name
+func tilesetMetadataFilename(extension string) string {
+	return strings.TrimSuffix(Output, filepath.Ext(Output)) + extension
+}
+
+func exitOnSaveError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving file: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func writeTilesetMetadata(format string, tileset i.TilesetConfig, verbose bool) (metadataFilename string) {
+	metadataFilename = tilesetMetadataFilename("." + format)
+	if verbose {
+		fmt.Printf("Saving tileset metadata to %s\n", metadataFilename)
+	}
+	switch format {
+	case "tsx":
+		exitOnSaveError(tileset.WriteTSX(metadataFilename, Output))
+	case "tsj":
+		exitOnSaveError(tileset.WriteTSJ(metadataFilename, Output))
+	}
+	return
+}
+
+// Every map is named after its image, so images with the same base name would
+// overwrite each other's maps
+func validateMapFilenames(filenames []string) error {
+	seen := map[string]string{}
+	for _, filename := range filenames {
+		name := mapFilename(filename, "")
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("%s and %s would be saved to the same map", other, filename)
+		}
+		seen[name] = filename
+	}
+	return nil
+}
+
+func writeMaps(filenames []string, tileMaps []i.TileMap, verbose bool) {
+	var metadataFilename string
+	switch mapFormat {
+	case "tmx":
+		metadataFilename = writeTilesetMetadata("tsx", tc, verbose)
+	case "tmj":
+		metadataFilename = writeTilesetMetadata("tsj", tc, verbose)
+	}
+
+	for j, filename := range filenames {
+		writeMap(filename, tileMaps[j], metadataFilename, verbose)
+	}
+}
+
+func writeMap(filename string, tileMap i.TileMap, metadataFilename string, verbose bool) {
+	switch mapFormat {
+	case "tmx":
+		tmxFilename := mapFilename(filename, ".tmx")
+		if verbose {
+			fmt.Printf("Saving map to %s\n", tmxFilename)
+		}
+		exitOnSaveError(tileMap.WriteTMX(tmxFilename, metadataFilename))
+	case "tmj":
+		tmjFilename := mapFilename(filename, ".tmj")
+		if verbose {
+			fmt.Printf("Saving map to %s\n", tmjFilename)
+		}
+		exitOnSaveError(tileMap.WriteTMJ(tmjFilename, metadataFilename))
+	case "ldtk":
+		ldtkFilename := mapFilename(filename, ".ldtk")
+		if verbose {
+			fmt.Printf("Saving map to %s\n", ldtkFilename)
+		}
+		exitOnSaveError(tileMap.WriteLDtk(ldtkFilename, Output, &tc))
+	case "csv":
+		csvFilename := mapFilename(filename, ".csv")
+		flagsFilename := mapFilename(filename, ".flags.csv")
+		if verbose {
+			fmt.Printf("Saving %dx%d map to %s with flags %s\n", tileMap.Columns, tileMap.Rows, csvFilename, flagsFilename)
+		}
+		exitOnSaveError(tileMap.WriteIndexCSV(csvFilename))
+		exitOnSaveError(tileMap.WriteFlagsCSV(flagsFilename))
+	case "bin":
+		binFilename := mapFilename(filename, ".bin")
+		flagsFilename := mapFilename(filename, ".flags.bin")
+		if verbose {
+			fmt.Printf("Saving %dx%d map to %s with flags %s\n", tileMap.Columns, tileMap.Rows, binFilename, flagsFilename)
+		}
+		exitOnSaveError(tileMap.WriteIndexBinary(binFilename))
+		exitOnSaveError(tileMap.WriteFlagsBinary(flagsFilename))
+	}
+}
+
+// Map formats that can only flip tiles need transformations that don't
+// swap the x and y axes
+func flipOnlyTransformations(transformations []string) []string {
+	flipOnly := []string{}
+	for _, transformation := range transformations {
+		if !i.FlipForTransformation(transformation).Diagonal {
+			flipOnly = append(flipOnly, transformation)
+		}
+	}
+	return flipOnly
+}
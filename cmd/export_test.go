@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+type ldtkTestTile struct {
+	Px  [2]int `json:"px"`
+	Src [2]int `json:"src"`
+	T   int    `json:"t"`
+	F   int    `json:"f"`
+}
+
+type ldtkTestProject struct {
+	IID  string `json:"iid"`
+	Defs struct {
+		Tilesets []struct {
+			CWid  int `json:"__cWid"`
+			CHei  int `json:"__cHei"`
+			PxWid int `json:"pxWid"`
+			PxHei int `json:"pxHei"`
+		} `json:"tilesets"`
+	} `json:"defs"`
+	Levels []struct {
+		LayerInstances []struct {
+			GridTiles []ldtkTestTile `json:"gridTiles"`
+		} `json:"layerInstances"`
+	} `json:"levels"`
+}
+
+type tiledTestTileset struct {
+	TileWidth  int `xml:"tilewidth,attr" json:"tilewidth"`
+	TileHeight int `xml:"tileheight,attr" json:"tileheight"`
+	Margin     int `xml:"margin,attr" json:"margin"`
+	Spacing    int `xml:"spacing,attr" json:"spacing"`
+	TileCount  int `xml:"tilecount,attr" json:"tilecount"`
+	Columns    int `xml:"columns,attr" json:"columns"`
+	ImageWidth int `xml:"-" json:"imagewidth"`
+	Image      struct {
+		Width int `xml:"width,attr"`
+	} `xml:"image" json:"-"`
+}
+
+func TestExtrusionMetadata(t *testing.T) {
+
+	// The tiles of test_02 in 3 columns with a margin of 1 and spacing of 2
+	thickness := 2
+	original := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	original.ReadImage(i.Open("../fixtures/test_02.png", false))
+	original.Margin = 1
+	original.Spacing = 2
+
+	// Extrude writes the extruded tiles with the input margin and spacing
+	extruded := original
+	extruded.TileWidth += 2 * thickness
+	extruded.TileHeight += 2 * thickness
+	extruded.TileImages = make([]*image.NRGBA, len(original.TileImages))
+	for j, tileImage := range original.TileImages {
+		extruded.TileImages[j] = extrudeTile(tileImage, thickness, "clamp", color.Transparent)
+	}
+	extrudedImage := extruded.ToImage()
+
+	// Respace keeps the extrusion, which is part of the output margin and spacing
+	respaceIn := i.NewTilesetConfig(testTileSize, testTileSize, original.Margin+thickness, original.Spacing+2*thickness, color.Transparent)
+	if err := respaceIn.ReadImage(extrudedImage); err != nil {
+		t.Fatal(err)
+	}
+	respaced := respaceTiles(extrudedImage, respaceIn, thickness, 5, 6, color.Transparent)
+
+	tests := []struct {
+		name            string
+		tileset         i.TilesetConfig
+		expectedMargin  int
+		expectedSpacing int
+	}{
+		{name: "extrude", tileset: extruded, expectedMargin: 3, expectedSpacing: 6},
+		{name: "respace", tileset: respaced, expectedMargin: 5, expectedSpacing: 6},
+	}
+
+	defer func(output string) { Output = output }(Output)
+	dir := t.TempDir()
+	for _, test := range tests {
+		Output = filepath.Join(dir, test.name+".png")
+		imageWidth := test.tileset.ToImage().Bounds().Dx()
+		metaTc := extrusionMetadata(test.tileset, testTileSize, testTileSize, thickness)
+		if metaTc.Margin != test.tileset.Margin+thickness || metaTc.Spacing != test.tileset.Spacing+2*thickness {
+			t.Errorf("%s: expected margin %d and spacing %d, got %d and %d", test.name,
+				test.tileset.Margin+thickness, test.tileset.Spacing+2*thickness, metaTc.Margin, metaTc.Spacing)
+		}
+
+		for _, format := range []string{"tsx", "tsj"} {
+			content, err := os.ReadFile(writeTilesetMetadata(format, metaTc, false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tileset tiledTestTileset
+			if format == "tsx" {
+				err = xml.Unmarshal(content, &tileset)
+				tileset.ImageWidth = tileset.Image.Width
+			} else {
+				err = json.Unmarshal(content, &tileset)
+			}
+			if err != nil {
+				t.Fatalf("%s %s: %s", test.name, format, err.Error())
+			}
+
+			// Tiled reads the original tiles out of the extruded image
+			if tileset.TileWidth != testTileSize || tileset.TileHeight != testTileSize {
+				t.Errorf("%s %s: expected %dx%d tiles, got %dx%d", test.name, format,
+					testTileSize, testTileSize, tileset.TileWidth, tileset.TileHeight)
+			}
+			if tileset.Margin != test.expectedMargin || tileset.Spacing != test.expectedSpacing {
+				t.Errorf("%s %s: expected margin %d and spacing %d, got %d and %d", test.name, format,
+					test.expectedMargin, test.expectedSpacing, tileset.Margin, tileset.Spacing)
+			}
+			if tileset.TileCount != 9 || tileset.Columns != 3 {
+				t.Errorf("%s %s: expected 9 tiles in 3 columns, got %d in %d", test.name, format,
+					tileset.TileCount, tileset.Columns)
+			}
+			if tileset.ImageWidth != imageWidth {
+				t.Errorf("%s %s: expected an image width of %d, got %d", test.name, format, imageWidth, tileset.ImageWidth)
+			}
+		}
+	}
+}
+
+func TestWriteLDtk(t *testing.T) {
+
+	// 3 tiles in 2 columns with a margin of 1 and spacing of 2
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 1, 2, color.Transparent)
+	tileset.Columns = 2
+	for j := 0; j < 3; j++ {
+		tileset.TileImages = append(tileset.TileImages, image.NewNRGBA(image.Rect(0, 0, testTileSize, testTileSize)))
+	}
+
+	tileMap := i.NewTileMap(2, 2, testTileSize, testTileSize)
+	tileMap.SetCell(0, 0, i.MapCell{Index: 0})
+	tileMap.SetCell(1, 0, i.MapCell{Index: 2, Flip: i.Flip{Horizontal: true}})
+	tileMap.SetCell(1, 1, i.MapCell{Index: 1, Flip: i.Flip{Horizontal: true, Vertical: true}})
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "map.ldtk")
+	if err := tileMap.WriteLDtk(filename, filepath.Join(dir, "tileset.png"), &tileset); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var project ldtkTestProject
+	if err := json.Unmarshal(content, &project); err != nil {
+		t.Fatal(err)
+	}
+
+	tilesetDef := project.Defs.Tilesets[0]
+	if tilesetDef.CWid != 2 || tilesetDef.PxWid != 36 || tilesetDef.CHei != 2 || tilesetDef.PxHei != 36 {
+		t.Errorf("expected a 2x2 grid of 36x36 pixels, got %dx%d of %dx%d",
+			tilesetDef.CWid, tilesetDef.CHei, tilesetDef.PxWid, tilesetDef.PxHei)
+	}
+
+	// The empty cell is left out
+	expected := []ldtkTestTile{
+		{Px: [2]int{0, 0}, Src: [2]int{1, 1}, T: 0, F: 0},
+		{Px: [2]int{16, 0}, Src: [2]int{1, 19}, T: 2, F: 1},
+		{Px: [2]int{16, 16}, Src: [2]int{19, 1}, T: 1, F: 3},
+	}
+	gridTiles := project.Levels[0].LayerInstances[0].GridTiles
+	if len(gridTiles) != len(expected) {
+		t.Fatalf("expected %d grid tiles, got %d", len(expected), len(gridTiles))
+	}
+	for j, gridTile := range gridTiles {
+		if gridTile != expected[j] {
+			t.Errorf("grid tile %d: expected %+v, got %+v", j, expected[j], gridTile)
+		}
+	}
+
+	// A project of the same name in another directory gets its own IIDs
+	otherFilename := filepath.Join(dir, "other", "map.ldtk")
+	if err := os.MkdirAll(filepath.Dir(otherFilename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := tileMap.WriteLDtk(otherFilename, filepath.Join(dir, "tileset.png"), &tileset); err != nil {
+		t.Fatal(err)
+	}
+	content, err = os.ReadFile(otherFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var otherProject ldtkTestProject
+	if err := json.Unmarshal(content, &otherProject); err != nil {
+		t.Fatal(err)
+	}
+	if project.IID == "" || otherProject.IID == project.IID {
+		t.Errorf("expected projects in different directories to have different IIDs, got %q and %q", project.IID, otherProject.IID)
+	}
+
+	tileMap.SetCell(0, 1, i.MapCell{Index: 0, Flip: i.Flip{Diagonal: true}})
+	if err := tileMap.WriteLDtk(filename, filepath.Join(dir, "tileset.png"), &tileset); err == nil {
+		t.Errorf("expected an error for a diagonally flipped cell")
+	}
+}
+
+func TestFlipOnlyTransformations(t *testing.T) {
+
+	expected := []string{"flipH-rotate180", "flipH-none", "flipV-rotate180", "flipV-none", "none-rotate180"}
+	flipOnly := flipOnlyTransformations(CreateTransformations())
+	if len(flipOnly) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, flipOnly)
+	}
+	for j, transformation := range flipOnly {
+		if transformation != expected[j] {
+			t.Errorf("expected %v, got %v", expected, flipOnly)
+			break
+		}
+	}
+}
+
+func TestWriteBinaryMap(t *testing.T) {
+
+	tileMap := i.NewTileMap(3, 2, testTileSize, testTileSize)
+	tileMap.SetCell(0, 0, i.MapCell{Index: 0})
+	tileMap.SetCell(2, 0, i.MapCell{Index: 258, Flip: i.Flip{Horizontal: true, Diagonal: true}})
+	tileMap.SetCell(0, 1, i.MapCell{Index: 1, Flip: i.Flip{Vertical: true}})
+	tileMap.SetCell(2, 1, i.MapCell{Index: 3, Flip: i.Flip{Horizontal: true, Vertical: true, Diagonal: true}})
+
+	dir := t.TempDir()
+	indexFilename := filepath.Join(dir, "map.bin")
+	flagsFilename := filepath.Join(dir, "map.flags.bin")
+	if err := tileMap.WriteIndexBinary(indexFilename); err != nil {
+		t.Fatal(err)
+	}
+	if err := tileMap.WriteFlagsBinary(flagsFilename); err != nil {
+		t.Fatal(err)
+	}
+
+	// Little-endian uint16s, row-major, with empty cells as 0xffff
+	expectedIndices := []byte{0x00, 0x00, 0xff, 0xff, 0x02, 0x01, 0x01, 0x00, 0xff, 0xff, 0x03, 0x00}
+	// One byte per cell: 4 is horizontal, 2 is vertical and 1 is diagonal
+	expectedFlags := []byte{0, 0, 5, 2, 0, 7}
+	for filename, expected := range map[string][]byte{indexFilename: expectedIndices, flagsFilename: expectedFlags} {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, expected) {
+			t.Errorf("%s: expected % x, got % x", filepath.Base(filename), expected, content)
+		}
+	}
+
+	tileMap.SetCell(1, 0, i.MapCell{Index: i.EmptyBinaryIndex})
+	if err := tileMap.WriteIndexBinary(indexFilename); err == nil {
+		t.Errorf("expected an error for an index too large for a binary map")
+	}
+}
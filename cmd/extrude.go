@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 	"os"
 
@@ -13,56 +14,72 @@ import (
 
 var extrudeCmd *cobra.Command
 var thickness int
-
-func extrudeTile(tileImage *image.NRGBA, thickness int) (extruded *image.NRGBA) {
-	extrudedRect := tileImage.Bounds().Inset(-thickness)
-	extruded = image.NewNRGBA(extrudedRect)
-	draw.Draw(extruded, tileImage.Bounds(), tileImage, tileImage.Bounds().Min, draw.Src)
-
-	// Horizontal
-	for x := tileImage.Bounds().Min.X; x < tileImage.Bounds().Max.X; x++ {
-		// Top
-		for y := extruded.Bounds().Min.Y; y < tileImage.Bounds().Min.Y; y++ {
-			extruded.Set(x, y, tileImage.At(x, tileImage.Bounds().Min.Y))
+var extrudeMode string
+
+var extrudeModes = []string{"clamp", "wrap", "mirror", "transparent", "color"}
+
+const validExtrudeModesMessage = "Valid modes are: \"clamp\" (repeat the edge pixels), \"wrap\" (repeat the opposite edge, for tiles drawn with texture repeat), \"mirror\" (reflect the tile), \"transparent\" and \"color\" (fill with the background color)."
+
+// extrusionSource maps a coordinate outside the span [min, max) to the
+// coordinate inside it that the extrusion copies
+func extrusionSource(v, min, max int, mode string) int {
+	length := max - min
+	offset := v - min
+	switch mode {
+	case "wrap":
+		offset = ((offset % length) + length) % length
+	case "mirror":
+		if offset < 0 {
+			offset = -offset - 1
 		}
-		// Bottom
-		for y := extruded.Bounds().Max.Y - 1; y > tileImage.Bounds().Max.Y-1; y-- {
-			extruded.Set(x, y, tileImage.At(x, tileImage.Bounds().Max.Y-1))
+		offset %= 2 * length
+		if offset >= length {
+			offset = (2 * length) - 1 - offset
 		}
-	}
-	// Vertical
-	for y := tileImage.Bounds().Min.Y; y < tileImage.Bounds().Max.Y; y++ {
-		// Left
-		for x := extruded.Bounds().Min.X; x < tileImage.Bounds().Min.X; x++ {
-			extruded.Set(x, y, tileImage.At(tileImage.Bounds().Min.X, y))
+	default:
+		if offset < 0 {
+			offset = 0
 		}
-		// Right
-		for x := extruded.Bounds().Max.X - 1; x > tileImage.Bounds().Max.X-1; x-- {
-			extruded.Set(x, y, tileImage.At(tileImage.Bounds().Max.X-1, y))
+		if offset > length-1 {
+			offset = length - 1
 		}
 	}
+	return min + offset
+}
 
-	// The four corners
-	// Left
-	for x := extruded.Bounds().Min.X; x < extruded.Bounds().Min.X+thickness; x++ {
-		// Top
-		for y := extruded.Bounds().Min.Y; y < extruded.Bounds().Min.Y+thickness; y++ {
-			extruded.Set(x, y, tileImage.At(tileImage.Bounds().Min.X, tileImage.Bounds().Min.Y))
-		}
-		// Bottom
-		for y := extruded.Bounds().Max.Y - 1; y > extruded.Bounds().Max.Y-thickness-1; y-- {
-			extruded.Set(x, y, tileImage.At(tileImage.Bounds().Min.X, tileImage.Bounds().Max.Y-1))
-		}
-	}
-	// Right
-	for x := extruded.Bounds().Max.X - 1; x > extruded.Bounds().Max.X-thickness-1; x-- {
-		// Top
-		for y := extruded.Bounds().Min.Y; y < extruded.Bounds().Min.Y+thickness; y++ {
-			extruded.Set(x, y, tileImage.At(tileImage.Bounds().Min.X, tileImage.Bounds().Min.Y))
-		}
-		// Bottom
-		for y := extruded.Bounds().Max.Y - 1; y > extruded.Bounds().Max.Y-thickness-1; y-- {
-			extruded.Set(x, y, tileImage.At(tileImage.Bounds().Min.X, tileImage.Bounds().Max.Y-1))
+// extrusionMetadata describes an extruded tileset the way Tiled sees it, with
+// tiles of the original size and the extrusion as part of the margin and
+// spacing around them
+func extrusionMetadata(tileset i.TilesetConfig, tileWidth, tileHeight, thickness int) i.TilesetConfig {
+	metaTc := tileset
+	metaTc.TileWidth = tileWidth
+	metaTc.TileHeight = tileHeight
+	metaTc.Margin = tileset.Margin + thickness
+	metaTc.Spacing = tileset.Spacing + 2*thickness
+	return metaTc
+}
+
+func extrudeTile(tileImage *image.NRGBA, thickness int, mode string, bgColor color.Color) (extruded *image.NRGBA) {
+	bounds := tileImage.Bounds()
+	extrudedRect := bounds.Inset(-thickness)
+	extruded = image.NewNRGBA(extrudedRect)
+	draw.Draw(extruded, bounds, tileImage, bounds.Min, draw.Src)
+
+	for y := extrudedRect.Min.Y; y < extrudedRect.Max.Y; y++ {
+		for x := extrudedRect.Min.X; x < extrudedRect.Max.X; x++ {
+			if image.Pt(x, y).In(bounds) {
+				continue
+			}
+			switch mode {
+			case "transparent":
+				extruded.Set(x, y, color.Transparent)
+			case "color":
+				extruded.Set(x, y, bgColor)
+			default:
+				extruded.Set(x, y, tileImage.At(
+					extrusionSource(x, bounds.Min.X, bounds.Max.X, mode),
+					extrusionSource(y, bounds.Min.Y, bounds.Max.Y, mode)))
+			}
 		}
 	}
 
@@ -74,7 +91,7 @@ func init() {
 	extrudeCmd = &cobra.Command{
 		Use:   "extrude <filename>",
 		Short: "Extrude the tiles of a tileset.",
-		Long:  "The extrude command copies tile content into the margin around, and spacing between, tiles. Extrusion mitigates texture bleeding or tearing during tileset map scrolling. The extrude command will increase the tileset margin by the amount of extrusion thickness and increase the tileset spacing by twice the extrusion thickness.",
+		Long:  "The extrude command copies tile content into the margin around, and spacing between, tiles. Extrusion mitigates texture bleeding or tearing during tileset map scrolling. The extrude command will increase the tileset margin by the amount of extrusion thickness and increase the tileset spacing by twice the extrusion thickness. The mode chooses what fills the extrusion: clamp repeats the tile's edge pixels, wrap repeats the opposite edge so that seamless tiles drawn with texture repeat stay seamless, mirror reflects the tile for smooth filtering, and transparent and color pad the tile, which helps to spot bleeding.",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) != 1 {
 				fmt.Fprintln(os.Stderr, "One arg required: <filename>")
@@ -88,12 +105,25 @@ func init() {
 				fmt.Fprintf(os.Stderr, "Invalid thickness: %s\n", err.Error())
 				os.Exit(1)
 			}
+			if err := i.ValidateChoice(extrudeMode, extrudeModes); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid mode: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(metadataFormat, metadataFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid metadata: %s\n", err.Error())
+				os.Exit(1)
+			}
+			validateSheetLayout()
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			filename := args[0]
 
 			img := i.Open(filename, Verbose)
-			tc.ReadImage(img)
+			applyAutoLayout(img)
+			if err := tc.ReadImage(img); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading tileset: %s\n", err.Error())
+				os.Exit(1)
+			}
 
 			outTc := tc
 			outTc.TileWidth += 2 * thickness
@@ -101,20 +131,31 @@ func init() {
 
 			etis := make([]*image.NRGBA, len(outTc.TileImages))
 			for i, tileImage := range outTc.TileImages {
-				extruded := extrudeTile(tileImage, thickness)
+				extruded := extrudeTile(tileImage, thickness, extrudeMode, BgColor)
 				etis[i] = extruded
 			}
 			outTc.TileImages = etis
 
 			if Verbose {
-				fmt.Printf("Extruding with thickness: %d\n", thickness)
+				fmt.Printf("Extruding with thickness: %d and mode: %s\n", thickness, extrudeMode)
 			}
 
-			fmt.Printf("Extruded tileset has margin: %d and spacing: %d\n", thickness, 2*thickness)
+			reflow(&outTc, Verbose)
+
+			metaTc := extrusionMetadata(outTc, tc.TileWidth, tc.TileHeight, thickness)
+
+			fmt.Printf("Extruded tileset has margin: %d and spacing: %d\n", metaTc.Margin, metaTc.Spacing)
 
 			tilesetImage := outTc.ToImage()
 			i.Save(tilesetImage, Output, Verbose)
+
+			if metadataFormat != "none" {
+				writeTilesetMetadata(metadataFormat, metaTc, Verbose)
+			}
 		},
 	}
 	extrudeCmd.Flags().IntVar(&thickness, "thickness", 1, "extrusion thickness in pixels (default 1)")
+	extrudeCmd.Flags().StringVar(&extrudeMode, "mode", "clamp", fmt.Sprintf("how to fill the extrusion. %s", validExtrudeModesMessage))
+	extrudeCmd.Flags().StringVar(&metadataFormat, "metadata", "none", fmt.Sprintf("tileset metadata format to write alongside the tileset. %s", validMetadataFormatsMessage))
+	addSheetLayoutFlags(extrudeCmd)
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestExtrudeModes(t *testing.T) {
+
+	// A 3x1 tile of red, green and blue
+	red := color.NRGBA{R: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+	tile := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	tile.SetNRGBA(0, 0, red)
+	tile.SetNRGBA(1, 0, green)
+	tile.SetNRGBA(2, 0, blue)
+
+	background := color.NRGBA{R: 255, G: 0, B: 255, A: 255}
+	transparent := color.NRGBA{}
+
+	// The extruded row through the tile, from x = -2 to x = 4
+	tests := map[string][]color.NRGBA{
+		"clamp":       {red, red, red, green, blue, blue, blue},
+		"wrap":        {green, blue, red, green, blue, red, green},
+		"mirror":      {green, red, red, green, blue, blue, green},
+		"transparent": {transparent, transparent, red, green, blue, transparent, transparent},
+		"color":       {background, background, red, green, blue, background, background},
+	}
+
+	for mode, expected := range tests {
+		t.Run(mode, func(t *testing.T) {
+			extruded := extrudeTile(tile, 2, mode, background)
+			if extruded.Bounds() != image.Rect(-2, -2, 5, 3) {
+				t.Fatalf("expected bounds %v, got %v", image.Rect(-2, -2, 5, 3), extruded.Bounds())
+			}
+			for j, c := range expected {
+				x := j - 2
+				if got := extruded.NRGBAAt(x, 0); got != c {
+					t.Errorf("x %d: expected %v, got %v", x, c, got)
+				}
+				// The tile is one pixel high, so every mode but the fills
+				// repeats the row above and below it
+				if mode != "transparent" && mode != "color" && extruded.NRGBAAt(x, -2) != c {
+					t.Errorf("x %d y -2: expected %v, got %v", x, c, extruded.NRGBAAt(x, -2))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var infoCmd *cobra.Command
+
+// applyAutoLayout replaces an auto margin or spacing with the value detected
+// from the tileset image
+func applyAutoLayout(img *image.NRGBA) {
+	if !autoMargin && !autoSpacing {
+		return
+	}
+	layout, err := i.DetectLayout(img, tc.TileWidth, tc.TileHeight)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error detecting layout: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if autoMargin {
+		tc.Margin = layout.Margin
+	}
+	if autoSpacing {
+		tc.Spacing = layout.Spacing
+	}
+	fmt.Printf("Detected margin: %d and spacing: %d\n", tc.Margin, tc.Spacing)
+}
+
+func outputInfoTable(layout i.TilesetLayout) {
+	size := fmt.Sprintf("%dx%d", layout.TileWidth, layout.TileHeight)
+	if !layout.SizeDetected {
+		size += " (from size)"
+	}
+	background := "none"
+	if layout.HasBackground {
+		background = i.HexFromColor(layout.Background)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Property", "Value"})
+	t.AppendRow(table.Row{"Tile Size", size})
+	t.AppendRow(table.Row{"Margin", fmt.Sprintf("%d", layout.Margin)})
+	t.AppendRow(table.Row{"Spacing", fmt.Sprintf("%d", layout.Spacing)})
+	t.AppendRow(table.Row{"Columns", fmt.Sprintf("%d", layout.Columns)})
+	t.AppendRow(table.Row{"Rows", fmt.Sprintf("%d", layout.Rows)})
+	t.AppendRow(table.Row{"Background Color", background})
+	t.Render()
+}
+
+func init() {
+
+	infoCmd = &cobra.Command{
+		Use:   "info <filename>",
+		Short: "Describe the layout of a tileset.",
+		Long:  "The info command infers the tile size, margin, spacing, columns, rows and background color of a tileset. The background is the most common color of the rows and columns that are a single color, and the margin and spacing are the widths of those gutter lines around and between tiles. A tileset without spacing has no gutters between tiles to measure, so its tile size is taken from the size flags.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "One arg required: <filename>")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool info --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filename := args[0]
+
+			img := i.Open(filename, Verbose)
+
+			layout, err := i.DetectLayout(img, tc.TileWidth, tc.TileHeight)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error detecting layout: %s\n", err.Error())
+				os.Exit(1)
+			}
+			outputInfoTable(layout)
+		},
+	}
+}
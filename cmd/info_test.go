@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"image/color"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestDetectLayout(t *testing.T) {
+
+	background := color.NRGBA{R: 255, G: 0, B: 255, A: 255}
+	layouts := []struct{ margin, spacing int }{{0, 1}, {2, 3}, {4, 2}}
+
+	for _, layout := range layouts {
+		tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, background)
+		tileset.ReadImage(i.Open("../fixtures/test_02.png", false))
+		tileset.Margin = layout.margin
+		tileset.Spacing = layout.spacing
+		tileset.Columns = 3
+
+		detected, err := i.DetectLayout(tileset.ToImage(), 8, 8)
+		if err != nil {
+			t.Fatalf("margin %d spacing %d: %s", layout.margin, layout.spacing, err.Error())
+		}
+		if !detected.SizeDetected || detected.TileWidth != testTileSize || detected.TileHeight != testTileSize {
+			t.Errorf("margin %d spacing %d: expected detected tile size %d, got %dx%d",
+				layout.margin, layout.spacing, testTileSize, detected.TileWidth, detected.TileHeight)
+		}
+		if detected.Margin != layout.margin || detected.Spacing != layout.spacing {
+			t.Errorf("expected margin %d spacing %d, got margin %d spacing %d",
+				layout.margin, layout.spacing, detected.Margin, detected.Spacing)
+		}
+		if detected.Columns != tileset.Columns || detected.Rows != tileset.Rows() {
+			t.Errorf("expected %dx%d tiles, got %dx%d", tileset.Columns, tileset.Rows(), detected.Columns, detected.Rows)
+		}
+		if detected.Background != background {
+			t.Errorf("expected background %v, got %v", background, detected.Background)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// Sprite is an image packed into an atlas. Trim is the part of the source
+// image that is kept, and Position is where its top left corner is placed in
+// the atlas, rotated 90 degrees clockwise when Rotated is set.
+type Sprite struct {
+	Name         string
+	Image        *image.NRGBA
+	SourceWidth  int
+	SourceHeight int
+	Trim         image.Rectangle
+	Position     image.Point
+	Rotated      bool
+}
+
+// AtlasConfig is how sprites are laid out in an atlas. Extrusion and Spacing
+// are kept around and between sprites, and Margin around the atlas.
+type AtlasConfig struct {
+	Margin    int
+	Spacing   int
+	Extrusion int
+	Rotate    bool
+	MaxWidth  int
+	Align     int
+	Pot       bool
+}
+
+// NewSprite makes a sprite of an image, trimming away its fully transparent
+// border when trim is set. A fully transparent image is trimmed to its top
+// left pixel.
+func NewSprite(name string, img *image.NRGBA, trim bool) Sprite {
+	bounds := img.Bounds()
+	sprite := Sprite{
+		Name:         name,
+		SourceWidth:  bounds.Dx(),
+		SourceHeight: bounds.Dy(),
+		Trim:         image.Rect(0, 0, bounds.Dx(), bounds.Dy()),
+	}
+	if trim {
+		opaque := image.Rectangle{}
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if img.NRGBAAt(x, y).A != 0 {
+					opaque = opaque.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		if opaque.Empty() {
+			opaque = image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+1, bounds.Min.Y+1)
+		}
+		sprite.Trim = opaque.Sub(bounds.Min)
+	}
+	sprite.Image = img.SubImage(sprite.Trim.Add(bounds.Min)).(*image.NRGBA)
+	return sprite
+}
+
+// Size is the size the sprite takes up in the atlas
+func (s Sprite) Size() (width, height int) {
+	if s.Rotated {
+		return s.Trim.Dy(), s.Trim.Dx()
+	}
+	return s.Trim.Dx(), s.Trim.Dy()
+}
+
+// maxRects tracks the free space of a bin as the maximal free rectangles
+type maxRects struct {
+	free []image.Rectangle
+}
+
+func newMaxRects(width, height int) *maxRects {
+	return &maxRects{free: []image.Rectangle{image.Rect(0, 0, width, height)}}
+}
+
+// find places a rectangle by the bottom left rule: as high up as possible,
+// then as far left as possible
+func (m *maxRects) find(width, height int, rotate bool) (rect image.Rectangle, rotated, ok bool) {
+	bestTop, bestLeft := math.MaxInt32, math.MaxInt32
+	try := func(free image.Rectangle, w, h int, r bool) {
+		if w > free.Dx() || h > free.Dy() {
+			return
+		}
+		top, left := free.Min.Y+h, free.Min.X
+		if top < bestTop || (top == bestTop && left < bestLeft) {
+			bestTop, bestLeft = top, left
+			rect = image.Rect(free.Min.X, free.Min.Y, free.Min.X+w, free.Min.Y+h)
+			rotated = r
+			ok = true
+		}
+	}
+	for _, free := range m.free {
+		try(free, width, height, false)
+		if rotate && width != height {
+			try(free, height, width, true)
+		}
+	}
+	return
+}
+
+// place splits the free rectangles around a used one, then drops the free
+// rectangles that are inside others
+func (m *maxRects) place(used image.Rectangle) {
+	split := []image.Rectangle{}
+	for _, free := range m.free {
+		if !free.Overlaps(used) {
+			split = append(split, free)
+			continue
+		}
+		if used.Min.X > free.Min.X {
+			split = append(split, image.Rect(free.Min.X, free.Min.Y, used.Min.X, free.Max.Y))
+		}
+		if used.Max.X < free.Max.X {
+			split = append(split, image.Rect(used.Max.X, free.Min.Y, free.Max.X, free.Max.Y))
+		}
+		if used.Min.Y > free.Min.Y {
+			split = append(split, image.Rect(free.Min.X, free.Min.Y, free.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < free.Max.Y {
+			split = append(split, image.Rect(free.Min.X, used.Max.Y, free.Max.X, free.Max.Y))
+		}
+	}
+
+	m.free = m.free[:0]
+	for j, a := range split {
+		contained := false
+		for k, b := range split {
+			if j != k && a.In(b) && (a != b || k < j) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			m.free = append(m.free, a)
+		}
+	}
+}
+
+// packWidth packs the sprites into a bin of the given width with unlimited
+// height, returning the atlas size before padding
+func packWidth(sprites []Sprite, order []int, binWidth int, config AtlasConfig) (width, height int, ok bool) {
+	gutter := config.Spacing + (2 * config.Extrusion)
+	binHeight := 0
+	for _, sprite := range sprites {
+		w, h := sprite.Trim.Dx(), sprite.Trim.Dy()
+		if h < w && config.Rotate {
+			h = w
+		}
+		binHeight += h + gutter
+	}
+
+	bin := newMaxRects(binWidth, binHeight)
+	right, bottom := 0, 0
+	for _, j := range order {
+		sprite := &sprites[j]
+		rect, rotated, found := bin.find(sprite.Trim.Dx()+gutter, sprite.Trim.Dy()+gutter, config.Rotate)
+		if !found {
+			return 0, 0, false
+		}
+		bin.place(rect)
+		sprite.Rotated = rotated
+		sprite.Position = rect.Min.Add(image.Pt(config.Margin+config.Extrusion, config.Margin+config.Extrusion))
+		if rect.Max.X > right {
+			right = rect.Max.X
+		}
+		if rect.Max.Y > bottom {
+			bottom = rect.Max.Y
+		}
+	}
+	width = right - config.Spacing + (2 * config.Margin)
+	height = bottom - config.Spacing + (2 * config.Margin)
+	return width, height, true
+}
+
+// PackSprites places sprites in the smallest atlas it finds by packing them
+// with MaxRects into bins of several widths. It returns the padded size of the
+// atlas.
+func PackSprites(sprites []Sprite, config AtlasConfig) (width, height int, err error) {
+	if len(sprites) == 0 {
+		return 0, 0, fmt.Errorf("no sprites to pack")
+	}
+
+	// Large sprites are placed first, ties in name order
+	order := make([]int, len(sprites))
+	for j := range order {
+		order[j] = j
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := sprites[order[a]].Trim, sprites[order[b]].Trim
+		la, lb := sa.Dx(), sb.Dx()
+		if sa.Dy() > la {
+			la = sa.Dy()
+		}
+		if sb.Dy() > lb {
+			lb = sb.Dy()
+		}
+		if la != lb {
+			return la > lb
+		}
+		if sa.Dx()*sa.Dy() != sb.Dx()*sb.Dy() {
+			return sa.Dx()*sa.Dy() > sb.Dx()*sb.Dy()
+		}
+		return sprites[order[a]].Name < sprites[order[b]].Name
+	})
+
+	gutter := config.Spacing + (2 * config.Extrusion)
+	minWidth, totalWidth, area := 0, 0, 0
+	for _, sprite := range sprites {
+		w, h := sprite.Trim.Dx()+gutter, sprite.Trim.Dy()+gutter
+		narrowest := w
+		if config.Rotate && h < narrowest {
+			narrowest = h
+		}
+		if narrowest > minWidth {
+			minWidth = narrowest
+		}
+		totalWidth += w
+		area += w * h
+	}
+
+	// The bin holds the sprites with their gutters, without the margin
+	toBin := func(atlasWidth int) int {
+		return atlasWidth + config.Spacing - (2 * config.Margin)
+	}
+	maxBinWidth := totalWidth
+	if config.MaxWidth > 0 {
+		maxBinWidth = toBin(config.MaxWidth)
+		if maxBinWidth < minWidth {
+			return 0, 0, fmt.Errorf("the widest sprite doesn't fit in the max width of %d", config.MaxWidth)
+		}
+	}
+
+	candidates := []int{minWidth, maxBinWidth}
+	side := int(math.Ceil(math.Sqrt(float64(area))))
+	for _, factor := range []float64{1, 1.25, 1.5, 2} {
+		candidates = append(candidates, int(float64(side)*factor))
+	}
+	for pot := 1; toBin(pot) <= maxBinWidth; pot *= 2 {
+		candidates = append(candidates, toBin(pot))
+	}
+
+	best, bestSide, bestArea := -1, 0, 0
+	for _, binWidth := range candidates {
+		if binWidth < minWidth || binWidth > maxBinWidth {
+			continue
+		}
+		w, h, ok := packWidth(sprites, order, binWidth, config)
+		if !ok {
+			continue
+		}
+		w, h = padDimension(w, config.Align, config.Pot), padDimension(h, config.Align, config.Pot)
+		// Padding can take a packing that fits past the max width
+		if config.MaxWidth > 0 && w > config.MaxWidth {
+			continue
+		}
+		longest := w
+		if h > longest {
+			longest = h
+		}
+		if best < 0 || w*h < bestArea || (w*h == bestArea && longest < bestSide) {
+			best, bestSide, bestArea = binWidth, longest, w*h
+			width, height = w, h
+		}
+	}
+	if best < 0 {
+		return 0, 0, fmt.Errorf("the sprites don't fit in the max width of %d", config.MaxWidth)
+	}
+
+	// Pack again with the best width to leave its placements in the sprites
+	packWidth(sprites, order, best, config)
+	return width, height, nil
+}
+
+type tpRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type tpSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type tpFrame struct {
+	Filename         string `json:"filename,omitempty"`
+	Frame            tpRect `json:"frame"`
+	Rotated          bool   `json:"rotated"`
+	Trimmed          bool   `json:"trimmed"`
+	SpriteSourceSize tpRect `json:"spriteSourceSize"`
+	SourceSize       tpSize `json:"sourceSize"`
+}
+
+type tpMeta struct {
+	App     string `json:"app"`
+	Version string `json:"version"`
+	Image   string `json:"image"`
+	Format  string `json:"format"`
+	Size    tpSize `json:"size"`
+	Scale   string `json:"scale"`
+}
+
+type tpHash struct {
+	Frames map[string]tpFrame `json:"frames"`
+	Meta   tpMeta             `json:"meta"`
+}
+
+type tpArray struct {
+	Frames []tpFrame `json:"frames"`
+	Meta   tpMeta    `json:"meta"`
+}
+
+// WriteTexturePackerJSON writes the frames of an atlas in TexturePacker's
+// JSON format, either as a hash keyed by sprite name or as an array. Frame
+// sizes are of the unrotated sprite.
+func WriteTexturePackerJSON(filename, imageFilename string, sprites []Sprite, width, height int, array bool, version string) error {
+	meta := tpMeta{
+		App:     "tiletool",
+		Version: version,
+		Image:   RelativePath(filename, imageFilename),
+		Format:  "RGBA8888",
+		Size:    tpSize{W: width, H: height},
+		Scale:   "1",
+	}
+	frames := make([]tpFrame, len(sprites))
+	for j, sprite := range sprites {
+		frames[j] = tpFrame{
+			Frame:   tpRect{X: sprite.Position.X, Y: sprite.Position.Y, W: sprite.Trim.Dx(), H: sprite.Trim.Dy()},
+			Rotated: sprite.Rotated,
+			Trimmed: sprite.Trim != image.Rect(0, 0, sprite.SourceWidth, sprite.SourceHeight),
+			SpriteSourceSize: tpRect{
+				X: sprite.Trim.Min.X, Y: sprite.Trim.Min.Y, W: sprite.Trim.Dx(), H: sprite.Trim.Dy(),
+			},
+			SourceSize: tpSize{W: sprite.SourceWidth, H: sprite.SourceHeight},
+		}
+	}
+
+	if array {
+		for j := range frames {
+			frames[j].Filename = sprites[j].Name
+		}
+		return writeJSON(filename, tpArray{Frames: frames, Meta: meta})
+	}
+	hash := tpHash{Frames: map[string]tpFrame{}, Meta: meta}
+	for j, frame := range frames {
+		hash.Frames[sprites[j].Name] = frame
+	}
+	return writeJSON(filename, hash)
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// Tiled stores flips in the high bits of a global tile id
+const (
+	FlippedHorizontallyFlag uint32 = 0x80000000
+	FlippedVerticallyFlag   uint32 = 0x40000000
+	FlippedDiagonallyFlag   uint32 = 0x20000000
+	FlipFlags                      = FlippedHorizontallyFlag | FlippedVerticallyFlag | FlippedDiagonallyFlag
+)
+
+// The transformation that leaves a tile as it is
+const IdentityTransformation = "none-none"
+
+// Flip describes how a tileset tile is drawn to reproduce a cell. It follows
+// the Tiled convention: the diagonal flip (x/y axis swap) is applied first,
+// followed by the horizontal and then the vertical flip.
+type Flip struct {
+	Horizontal bool
+	Vertical   bool
+	Diagonal   bool
+}
+
+// The flip that undoes each transformation, so that drawing the matched
+// tileset tile with it reproduces the original crop
+var transformationFlips = map[string]Flip{
+	"flipH-rotate90":       {Horizontal: false, Vertical: false, Diagonal: true},
+	"flipH-rotate180":      {Horizontal: false, Vertical: true, Diagonal: false},
+	"flipH-rotate270":      {Horizontal: true, Vertical: true, Diagonal: true},
+	"flipH-none":           {Horizontal: true, Vertical: false, Diagonal: false},
+	"flipV-rotate90":       {Horizontal: true, Vertical: true, Diagonal: true},
+	"flipV-rotate180":      {Horizontal: true, Vertical: false, Diagonal: false},
+	"flipV-rotate270":      {Horizontal: false, Vertical: false, Diagonal: true},
+	"flipV-none":           {Horizontal: false, Vertical: true, Diagonal: false},
+	"none-rotate90":        {Horizontal: true, Vertical: false, Diagonal: true},
+	"none-rotate180":       {Horizontal: true, Vertical: true, Diagonal: false},
+	"none-rotate270":       {Horizontal: false, Vertical: true, Diagonal: true},
+	IdentityTransformation: {Horizontal: false, Vertical: false, Diagonal: false},
+}
+
+func FlipForTransformation(transformation string) Flip {
+	return transformationFlips[transformation]
+}
+
+func FlipFromBits(bits uint32) Flip {
+	return Flip{
+		Horizontal: bits&FlippedHorizontallyFlag != 0,
+		Vertical:   bits&FlippedVerticallyFlag != 0,
+		Diagonal:   bits&FlippedDiagonallyFlag != 0,
+	}
+}
+
+func (f Flip) Bits() (bits uint32) {
+	if f.Horizontal {
+		bits |= FlippedHorizontallyFlag
+	}
+	if f.Vertical {
+		bits |= FlippedVerticallyFlag
+	}
+	if f.Diagonal {
+		bits |= FlippedDiagonallyFlag
+	}
+	return
+}
+
+func (f Flip) Apply(img *image.NRGBA) *image.NRGBA {
+	if f.Diagonal {
+		img = imaging.Transpose(img)
+	}
+	if f.Horizontal {
+		img = imaging.FlipH(img)
+	}
+	if f.Vertical {
+		img = imaging.FlipV(img)
+	}
+	return img
+}
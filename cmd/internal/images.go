@@ -5,6 +5,7 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"math"
 	"os"
 	"strings"
 
@@ -18,8 +19,8 @@ func ColorFromHex(hex string) (c color.RGBA, err error) {
 	return
 }
 func HexFromColor(c color.Color) (hex string) {
-	r, g, b, a := c.RGBA()
-	hex = fmt.Sprintf("#%02x%02x%02x%02x", r, g, b, a)
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	hex = fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
 	return
 }
 
@@ -59,6 +60,25 @@ func GetContigousSubPixels(img *image.NRGBA) []byte {
 	return nrgba.Pix
 }
 
+// IsUniform reports whether every pixel of the image is the given color. Fully
+// transparent pixels match any fully transparent color.
+func IsUniform(img *image.NRGBA, c color.Color) bool {
+	target := color.NRGBAModel.Convert(c).(color.NRGBA)
+	rect := img.Bounds()
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			pixel := img.NRGBAAt(x, y)
+			if pixel.A == 0 && target.A == 0 {
+				continue
+			}
+			if pixel != target {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func ImageToNRGBA(src image.Image) *image.NRGBA {
 	if dst, ok := src.(*image.NRGBA); ok {
 		return dst
@@ -68,3 +88,100 @@ func ImageToNRGBA(src image.Image) *image.NRGBA {
 	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
 	return dst
 }
+
+// MeanColor averages the pixels of the image, weighting each by its alpha
+func MeanColor(img *image.NRGBA) color.NRGBA {
+	var r, g, b, a float64
+	rect := img.Bounds()
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			pixel := img.NRGBAAt(x, y)
+			alpha := float64(pixel.A)
+			r += float64(pixel.R) * alpha
+			g += float64(pixel.G) * alpha
+			b += float64(pixel.B) * alpha
+			a += alpha
+		}
+	}
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	pixels := float64(rect.Dx() * rect.Dy())
+	return color.NRGBA{
+		R: uint8(math.Round(r / a)),
+		G: uint8(math.Round(g / a)),
+		B: uint8(math.Round(b / a)),
+		A: uint8(math.Round(a / pixels)),
+	}
+}
+
+// Luminance is the relative luminance of a color in [0, 1], using the
+// Rec. 709 coefficients
+func Luminance(c color.NRGBA) float64 {
+	return (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / 255
+}
+
+// Hue is the hue of a color in degrees [0, 360). Greys have a hue of 0.
+func Hue(c color.NRGBA) float64 {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	delta := max - min
+	if delta == 0 {
+		return 0
+	}
+	var hue float64
+	switch max {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = ((b - r) / delta) + 2
+	default:
+		hue = ((r - g) / delta) + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+	return hue
+}
+
+// MaxChannelDistance is the largest difference between any channel of any
+// pixel of two images of the same size, in [0, 255]
+func MaxChannelDistance(a, b *image.NRGBA) float64 {
+	pixelsA := GetContigousSubPixels(a)
+	pixelsB := GetContigousSubPixels(b)
+	var distance float64
+	for j := range pixelsA {
+		distance = math.Max(distance, math.Abs(float64(pixelsA[j])-float64(pixelsB[j])))
+	}
+	return distance
+}
+
+// MeanChannelDistance is the mean absolute difference between the channels
+// of the pixels of two images of the same size, in [0, 255]
+func MeanChannelDistance(a, b *image.NRGBA) float64 {
+	pixelsA := GetContigousSubPixels(a)
+	pixelsB := GetContigousSubPixels(b)
+	if len(pixelsA) == 0 {
+		return 0
+	}
+	var distance float64
+	for j := range pixelsA {
+		distance += math.Abs(float64(pixelsA[j]) - float64(pixelsB[j]))
+	}
+	return distance / float64(len(pixelsA))
+}
+
+// SquaredDistance sums the squared differences of every channel of every
+// pixel of two images of the same size
+func SquaredDistance(a, b *image.NRGBA) float64 {
+	pixelsA := GetContigousSubPixels(a)
+	pixelsB := GetContigousSubPixels(b)
+	var distance float64
+	for j := range pixelsA {
+		d := float64(pixelsA[j]) - float64(pixelsB[j])
+		distance += d * d
+	}
+	return distance
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+// Empty cells are written as the largest index in binary index maps
+const EmptyBinaryIndex = 0xffff
+
+// FlagBits packs a flip into the three bits Tiled uses, shifted down:
+// 4 is horizontal, 2 is vertical and 1 is diagonal.
+func (f Flip) FlagBits() uint8 {
+	return uint8(f.Bits() >> 29)
+}
+
+func (tm *TileMap) writeCSV(filename string, value func(cell MapCell) string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	for row := 0; row < tm.Rows; row++ {
+		record := make([]string, tm.Columns)
+		for column := 0; column < tm.Columns; column++ {
+			record[column] = value(tm.Cell(column, row))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// WriteIndexCSV writes one row of tileset indices per map row. Empty cells
+// are -1.
+func (tm *TileMap) WriteIndexCSV(filename string) error {
+	return tm.writeCSV(filename, func(cell MapCell) string {
+		return strconv.Itoa(cell.Index)
+	})
+}
+
+func (tm *TileMap) WriteFlagsCSV(filename string) error {
+	return tm.writeCSV(filename, func(cell MapCell) string {
+		return strconv.Itoa(int(cell.Flip.FlagBits()))
+	})
+}
+
+// WriteIndexBinary writes the tileset indices row-major as little-endian
+// uint16s
+func (tm *TileMap) WriteIndexBinary(filename string) error {
+	indices := make([]uint16, len(tm.Cells))
+	for j, cell := range tm.Cells {
+		if cell.Index >= EmptyBinaryIndex {
+			return fmt.Errorf("tileset index too large for binary map: %d", cell.Index)
+		}
+		if cell.Index < 0 {
+			indices[j] = EmptyBinaryIndex
+		} else {
+			indices[j] = uint16(cell.Index)
+		}
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return binary.Write(file, binary.LittleEndian, indices)
+}
+
+// WriteFlagsBinary writes one byte of flip flags per cell, row-major
+func (tm *TileMap) WriteFlagsBinary(filename string) error {
+	flags := make([]byte, len(tm.Cells))
+	for j, cell := range tm.Cells {
+		flags[j] = cell.Flip.FlagBits()
+	}
+	return os.WriteFile(filename, flags, 0644)
+}
+
+func readCSV(filename string) ([][]int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]int, len(records))
+	for row, record := range records {
+		values[row] = make([]int, len(record))
+		for column, field := range record {
+			value, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: row %d, column %d: %s", filename, row, column, err.Error())
+			}
+			values[row][column] = value
+		}
+	}
+	return values, nil
+}
+
+// ReadIndexCSV reads a map written by WriteIndexCSV. The flags sidecar is
+// optional; without it no cells are flipped.
+func ReadIndexCSV(filename, flagsFilename string, tileWidth, tileHeight int) (TileMap, error) {
+	indices, err := readCSV(filename)
+	if err != nil {
+		return TileMap{}, err
+	}
+	rows := len(indices)
+	columns := 0
+	if rows > 0 {
+		columns = len(indices[0])
+	}
+
+	flags, err := readCSV(flagsFilename)
+	if errors.Is(err, fs.ErrNotExist) {
+		flags = nil
+	} else if err != nil {
+		return TileMap{}, err
+	} else if len(flags) != rows {
+		return TileMap{}, fmt.Errorf("flags have %d rows, expected %d", len(flags), rows)
+	}
+
+	tileMap := NewTileMap(columns, rows, tileWidth, tileHeight)
+	for row := 0; row < rows; row++ {
+		if len(indices[row]) != columns {
+			return TileMap{}, fmt.Errorf("map row %d has %d columns, expected %d", row, len(indices[row]), columns)
+		}
+		for column := 0; column < columns; column++ {
+			cell := MapCell{Index: indices[row][column]}
+			if flags != nil {
+				if len(flags[row]) != columns {
+					return TileMap{}, fmt.Errorf("flags row %d has %d columns, expected %d", row, len(flags[row]), columns)
+				}
+				cell.Flip = FlipFromBits(uint32(flags[row][column]) << 29)
+			}
+			tileMap.SetCell(column, row, cell)
+		}
+	}
+	return tileMap, nil
+}
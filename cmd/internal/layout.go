@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// TilesetLayout is the arrangement of tiles in a tileset image, as detected
+// from the uniform gutter lines between them
+type TilesetLayout struct {
+	TileWidth     int
+	TileHeight    int
+	Margin        int
+	Spacing       int
+	Columns       int
+	Rows          int
+	Background    color.NRGBA
+	HasBackground bool
+	SizeDetected  bool
+}
+
+func uniformColor(img *image.NRGBA, rect image.Rectangle) (color.NRGBA, bool) {
+	c := img.NRGBAAt(rect.Min.X, rect.Min.Y)
+	if c.A == 0 {
+		// Every fully transparent color is the same background
+		c = color.NRGBA{}
+	}
+	sub := img.SubImage(rect).(*image.NRGBA)
+	return c, IsUniform(sub, c)
+}
+
+// colorKey orders colors so that ties between background candidates are
+// broken the same way on every run
+func colorKey(c color.NRGBA) uint32 {
+	return uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+}
+
+// gutterMask marks the lines (columns, or rows when vertical) of the image
+// that are entirely the background color
+func gutterMask(img *image.NRGBA, background color.NRGBA, vertical bool) []bool {
+	bounds := img.Bounds()
+	length := bounds.Dx()
+	if vertical {
+		length = bounds.Dy()
+	}
+	mask := make([]bool, length)
+	for j := 0; j < length; j++ {
+		rect := image.Rect(bounds.Min.X+j, bounds.Min.Y, bounds.Min.X+j+1, bounds.Max.Y)
+		if vertical {
+			rect = image.Rect(bounds.Min.X, bounds.Min.Y+j, bounds.Max.X, bounds.Min.Y+j+1)
+		}
+		mask[j] = IsUniform(img.SubImage(rect).(*image.NRGBA), background)
+	}
+	return mask
+}
+
+// gutterRuns measures the gutter at the start of the mask and the shortest
+// gutter between content. Tiles with background colored edges can only make
+// gutters look wider, so the shortest ones are taken.
+func gutterRuns(mask []bool) (margin, spacing int, hasSpacing bool) {
+	length := len(mask)
+	leading := 0
+	for leading < length && mask[leading] {
+		leading++
+	}
+	if leading == length {
+		return 0, 0, false
+	}
+	trailing := 0
+	for trailing < length && mask[length-1-trailing] {
+		trailing++
+	}
+	margin = leading
+	if trailing < margin {
+		margin = trailing
+	}
+
+	run := 0
+	for j := leading; j < length-trailing; j++ {
+		if mask[j] {
+			run++
+			continue
+		}
+		if run > 0 && (!hasSpacing || run < spacing) {
+			spacing = run
+			hasSpacing = true
+		}
+		run = 0
+	}
+	return
+}
+
+// tileLength finds the smallest tile length for which every spacing line
+// of the layout is a gutter
+func tileLength(mask []bool, margin, spacing int) (int, bool) {
+	length := len(mask)
+	for tile := 1; tile <= length-(2*margin); tile++ {
+		pitch := tile + spacing
+		if (length-(2*margin)+spacing)%pitch != 0 {
+			continue
+		}
+		consistent := true
+		for start := margin + tile; start < length-margin && consistent; start += pitch {
+			for j := start; j < start+spacing; j++ {
+				if !mask[j] {
+					consistent = false
+					break
+				}
+			}
+		}
+		if consistent {
+			return tile, true
+		}
+	}
+	return 0, false
+}
+
+// DetectLayout infers the layout of a tileset image. The background is the
+// most common color of lines that are a single color. Without spacing there
+// are no gutters between tiles to measure, so the fallback tile size is used.
+func DetectLayout(img *image.NRGBA, fallbackWidth, fallbackHeight int) (TilesetLayout, error) {
+	layout := TilesetLayout{TileWidth: fallbackWidth, TileHeight: fallbackHeight}
+	bounds := img.Bounds()
+
+	counts := map[color.NRGBA]int{}
+	for x := bounds.Min.X; x < bounds.Max.X; x++ {
+		if c, ok := uniformColor(img, image.Rect(x, bounds.Min.Y, x+1, bounds.Max.Y)); ok {
+			counts[c]++
+		}
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		if c, ok := uniformColor(img, image.Rect(bounds.Min.X, y, bounds.Max.X, y+1)); ok {
+			counts[c]++
+		}
+	}
+	for c, count := range counts {
+		best := counts[layout.Background]
+		if !layout.HasBackground || count > best || (count == best && colorKey(c) < colorKey(layout.Background)) {
+			layout.Background = c
+			layout.HasBackground = true
+		}
+	}
+
+	if layout.HasBackground {
+		columnMask := gutterMask(img, layout.Background, false)
+		rowMask := gutterMask(img, layout.Background, true)
+		marginX, spacingX, hasSpacingX := gutterRuns(columnMask)
+		marginY, spacingY, hasSpacingY := gutterRuns(rowMask)
+
+		layout.Margin = marginX
+		if marginY < layout.Margin {
+			layout.Margin = marginY
+		}
+		switch {
+		case hasSpacingX && hasSpacingY:
+			layout.Spacing = spacingX
+			if spacingY < layout.Spacing {
+				layout.Spacing = spacingY
+			}
+		case hasSpacingX:
+			layout.Spacing = spacingX
+		case hasSpacingY:
+			layout.Spacing = spacingY
+		}
+
+		if layout.Spacing > 0 {
+			tileWidth, okX := tileLength(columnMask, layout.Margin, layout.Spacing)
+			tileHeight, okY := tileLength(rowMask, layout.Margin, layout.Spacing)
+			// An axis with gutters between content has to hold more than one
+			// tile, otherwise the gutters are part of the tile
+			okX = okX && (!hasSpacingX || tileWidth < len(columnMask)-(2*layout.Margin))
+			okY = okY && (!hasSpacingY || tileHeight < len(rowMask)-(2*layout.Margin))
+			if okX && okY {
+				layout.TileWidth = tileWidth
+				layout.TileHeight = tileHeight
+				layout.SizeDetected = true
+			} else {
+				layout.Spacing = 0
+			}
+		}
+	}
+
+	if !layout.SizeDetected {
+		// Tiles with background colored edges look like margin when there is
+		// no spacing to compare with, so take the smallest margin that fits
+		for margin := 0; margin < layout.Margin; margin++ {
+			if (bounds.Dx()+layout.Spacing-(2*margin))%(layout.TileWidth+layout.Spacing) == 0 &&
+				(bounds.Dy()+layout.Spacing-(2*margin))%(layout.TileHeight+layout.Spacing) == 0 {
+				layout.Margin = margin
+				break
+			}
+		}
+	}
+
+	tileableWidth := bounds.Dx() + layout.Spacing - (2 * layout.Margin)
+	tileableHeight := bounds.Dy() + layout.Spacing - (2 * layout.Margin)
+	if tileableWidth%(layout.TileWidth+layout.Spacing) != 0 || tileableHeight%(layout.TileHeight+layout.Spacing) != 0 {
+		return layout, fmt.Errorf(
+			"detected margin: %d and spacing: %d don't fit tiles of size %dx%d in a %dx%d image",
+			layout.Margin, layout.Spacing, layout.TileWidth, layout.TileHeight, bounds.Dx(), bounds.Dy())
+	}
+	layout.Columns = tileableWidth / (layout.TileWidth + layout.Spacing)
+	layout.Rows = tileableHeight / (layout.TileHeight + layout.Spacing)
+	return layout, nil
+}
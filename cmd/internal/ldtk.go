@@ -0,0 +1,354 @@
+package internal
+
+import (
+	"crypto/md5"
+	"fmt"
+	"path/filepath"
+)
+
+const LDtkVersion = "1.5.3"
+
+// LDtk only stores horizontal and vertical flips for tiles
+const (
+	ldtkFlipX = 1
+	ldtkFlipY = 2
+)
+
+// Unique ids of the definitions in an exported project
+const (
+	ldtkTilesetUID = 1
+	ldtkLayerUID   = 2
+	ldtkLevelUID   = 3
+	ldtkNextUID    = 4
+)
+
+type ldtkHeader struct {
+	FileType   string `json:"fileType"`
+	App        string `json:"app"`
+	Doc        string `json:"doc"`
+	Schema     string `json:"schema"`
+	AppAuthor  string `json:"appAuthor"`
+	AppVersion string `json:"appVersion"`
+	URL        string `json:"url"`
+}
+
+type ldtkTilesetDef struct {
+	CWid              int           `json:"__cWid"`
+	CHei              int           `json:"__cHei"`
+	Identifier        string        `json:"identifier"`
+	UID               int           `json:"uid"`
+	RelPath           string        `json:"relPath"`
+	EmbedAtlas        *string       `json:"embedAtlas"`
+	PxWid             int           `json:"pxWid"`
+	PxHei             int           `json:"pxHei"`
+	TileGridSize      int           `json:"tileGridSize"`
+	Spacing           int           `json:"spacing"`
+	Padding           int           `json:"padding"`
+	Tags              []string      `json:"tags"`
+	TagsSourceEnumUID *int          `json:"tagsSourceEnumUid"`
+	EnumTags          []interface{} `json:"enumTags"`
+	CustomData        []interface{} `json:"customData"`
+	SavedSelections   []interface{} `json:"savedSelections"`
+	CachedPixelData   interface{}   `json:"cachedPixelData"`
+}
+
+type ldtkLayerDef struct {
+	Type                   string        `json:"__type"`
+	Identifier             string        `json:"identifier"`
+	LayerType              string        `json:"type"`
+	UID                    int           `json:"uid"`
+	Doc                    *string       `json:"doc"`
+	UIColor                *string       `json:"uiColor"`
+	GridSize               int           `json:"gridSize"`
+	GuideGridWid           int           `json:"guideGridWid"`
+	GuideGridHei           int           `json:"guideGridHei"`
+	DisplayOpacity         float64       `json:"displayOpacity"`
+	InactiveOpacity        float64       `json:"inactiveOpacity"`
+	HideInList             bool          `json:"hideInList"`
+	HideFieldsWhenInactive bool          `json:"hideFieldsWhenInactive"`
+	CanSelectWhenInactive  bool          `json:"canSelectWhenInactive"`
+	RenderInWorldView      bool          `json:"renderInWorldView"`
+	PxOffsetX              int           `json:"pxOffsetX"`
+	PxOffsetY              int           `json:"pxOffsetY"`
+	ParallaxFactorX        float64       `json:"parallaxFactorX"`
+	ParallaxFactorY        float64       `json:"parallaxFactorY"`
+	ParallaxScaling        bool          `json:"parallaxScaling"`
+	RequiredTags           []string      `json:"requiredTags"`
+	ExcludedTags           []string      `json:"excludedTags"`
+	UIFilterTags           []string      `json:"uiFilterTags"`
+	IntGridValues          []interface{} `json:"intGridValues"`
+	IntGridValuesGroups    []interface{} `json:"intGridValuesGroups"`
+	AutoRuleGroups         []interface{} `json:"autoRuleGroups"`
+	AutoSourceLayerDefUID  *int          `json:"autoSourceLayerDefUid"`
+	TilesetDefUID          int           `json:"tilesetDefUid"`
+	TilePivotX             float64       `json:"tilePivotX"`
+	TilePivotY             float64       `json:"tilePivotY"`
+}
+
+type ldtkDefs struct {
+	Layers        []ldtkLayerDef   `json:"layers"`
+	Entities      []interface{}    `json:"entities"`
+	Tilesets      []ldtkTilesetDef `json:"tilesets"`
+	Enums         []interface{}    `json:"enums"`
+	ExternalEnums []interface{}    `json:"externalEnums"`
+	LevelFields   []interface{}    `json:"levelFields"`
+}
+
+type ldtkTile struct {
+	Px  [2]int  `json:"px"`
+	Src [2]int  `json:"src"`
+	F   int     `json:"f"`
+	T   int     `json:"t"`
+	D   []int   `json:"d"`
+	A   float64 `json:"a"`
+}
+
+type ldtkLayerInstance struct {
+	Identifier         string        `json:"__identifier"`
+	Type               string        `json:"__type"`
+	CWid               int           `json:"__cWid"`
+	CHei               int           `json:"__cHei"`
+	GridSize           int           `json:"__gridSize"`
+	Opacity            float64       `json:"__opacity"`
+	PxTotalOffsetX     int           `json:"__pxTotalOffsetX"`
+	PxTotalOffsetY     int           `json:"__pxTotalOffsetY"`
+	TilesetDefUID      int           `json:"__tilesetDefUid"`
+	TilesetRelPath     string        `json:"__tilesetRelPath"`
+	IID                string        `json:"iid"`
+	LevelID            int           `json:"levelId"`
+	LayerDefUID        int           `json:"layerDefUid"`
+	PxOffsetX          int           `json:"pxOffsetX"`
+	PxOffsetY          int           `json:"pxOffsetY"`
+	Visible            bool          `json:"visible"`
+	OptionalRules      []interface{} `json:"optionalRules"`
+	IntGridCsv         []int         `json:"intGridCsv"`
+	AutoLayerTiles     []ldtkTile    `json:"autoLayerTiles"`
+	Seed               int           `json:"seed"`
+	OverrideTilesetUID *int          `json:"overrideTilesetUid"`
+	GridTiles          []ldtkTile    `json:"gridTiles"`
+	EntityInstances    []interface{} `json:"entityInstances"`
+}
+
+type ldtkLevel struct {
+	Identifier        string              `json:"identifier"`
+	IID               string              `json:"iid"`
+	UID               int                 `json:"uid"`
+	WorldX            int                 `json:"worldX"`
+	WorldY            int                 `json:"worldY"`
+	WorldDepth        int                 `json:"worldDepth"`
+	PxWid             int                 `json:"pxWid"`
+	PxHei             int                 `json:"pxHei"`
+	BgColorComputed   string              `json:"__bgColor"`
+	BgColor           *string             `json:"bgColor"`
+	UseAutoIdentifier bool                `json:"useAutoIdentifier"`
+	BgRelPath         *string             `json:"bgRelPath"`
+	BgPos             *string             `json:"bgPos"`
+	BgPivotX          float64             `json:"bgPivotX"`
+	BgPivotY          float64             `json:"bgPivotY"`
+	SmartColor        string              `json:"__smartColor"`
+	ExternalRelPath   *string             `json:"externalRelPath"`
+	FieldInstances    []interface{}       `json:"fieldInstances"`
+	LayerInstances    []ldtkLayerInstance `json:"layerInstances"`
+	Neighbours        []interface{}       `json:"__neighbours"`
+}
+
+type ldtkProject struct {
+	Header              ldtkHeader    `json:"__header__"`
+	IID                 string        `json:"iid"`
+	JSONVersion         string        `json:"jsonVersion"`
+	AppBuildID          int           `json:"appBuildId"`
+	NextUID             int           `json:"nextUid"`
+	IdentifierStyle     string        `json:"identifierStyle"`
+	Toc                 []interface{} `json:"toc"`
+	WorldLayout         string        `json:"worldLayout"`
+	WorldGridWidth      int           `json:"worldGridWidth"`
+	WorldGridHeight     int           `json:"worldGridHeight"`
+	DefaultLevelWidth   int           `json:"defaultLevelWidth"`
+	DefaultLevelHeight  int           `json:"defaultLevelHeight"`
+	DefaultPivotX       float64       `json:"defaultPivotX"`
+	DefaultPivotY       float64       `json:"defaultPivotY"`
+	DefaultGridSize     int           `json:"defaultGridSize"`
+	DefaultEntityWidth  int           `json:"defaultEntityWidth"`
+	DefaultEntityHeight int           `json:"defaultEntityHeight"`
+	BgColor             string        `json:"bgColor"`
+	DefaultLevelBgColor string        `json:"defaultLevelBgColor"`
+	MinifyJSON          bool          `json:"minifyJson"`
+	ExternalLevels      bool          `json:"externalLevels"`
+	ExportTiled         bool          `json:"exportTiled"`
+	SimplifiedExport    bool          `json:"simplifiedExport"`
+	ImageExportMode     string        `json:"imageExportMode"`
+	ExportLevelBg       bool          `json:"exportLevelBg"`
+	PngFilePattern      *string       `json:"pngFilePattern"`
+	BackupOnSave        bool          `json:"backupOnSave"`
+	BackupLimit         int           `json:"backupLimit"`
+	BackupRelPath       *string       `json:"backupRelPath"`
+	LevelNamePattern    string        `json:"levelNamePattern"`
+	TutorialDesc        *string       `json:"tutorialDesc"`
+	CustomCommands      []interface{} `json:"customCommands"`
+	Flags               []interface{} `json:"flags"`
+	Defs                ldtkDefs      `json:"defs"`
+	Levels              []ldtkLevel   `json:"levels"`
+	Worlds              []interface{} `json:"worlds"`
+	DummyWorldIID       string        `json:"dummyWorldIid"`
+}
+
+// LDtk identifies projects, levels and layers with UUIDs. They are derived
+// from the project file path so that re-exporting doesn't churn them, and
+// projects with the same name in different directories don't share them.
+func ldtkIID(filename, name string) string {
+	sum := md5.Sum([]byte(filepath.ToSlash(filepath.Clean(filename)) + "/" + name))
+	sum[6] = (sum[6] & 0x0f) | 0x30
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func (f Flip) LDtkBits() (bits int) {
+	if f.Horizontal {
+		bits |= ldtkFlipX
+	}
+	if f.Vertical {
+		bits |= ldtkFlipY
+	}
+	return
+}
+
+func (tm *TileMap) WriteLDtk(filename, imageFilename string, tileset *TilesetConfig) error {
+	if tm.TileWidth != tm.TileHeight {
+		return fmt.Errorf("LDtk only supports square tiles: tile size: %dx%d", tm.TileWidth, tm.TileHeight)
+	}
+	gridSize := tm.TileWidth
+	imageWidth, imageHeight := tileset.Dims()
+	relPath := RelativePath(filename, imageFilename)
+
+	gridTiles := []ldtkTile{}
+	for row := 0; row < tm.Rows; row++ {
+		for column := 0; column < tm.Columns; column++ {
+			cell := tm.Cell(column, row)
+			if cell.Index < 0 {
+				continue
+			}
+			if cell.Flip.Diagonal {
+				return fmt.Errorf("LDtk can't draw rotated tiles: cell: %d,%d", column, row)
+			}
+			src := tileset.TilePosition(cell.Index/tileset.Columns, cell.Index%tileset.Columns)
+			gridTiles = append(gridTiles, ldtkTile{
+				Px:  [2]int{column * gridSize, row * gridSize},
+				Src: [2]int{src.X, src.Y},
+				F:   cell.Flip.LDtkBits(),
+				T:   cell.Index,
+				D:   []int{(row * tm.Columns) + column},
+				A:   1,
+			})
+		}
+	}
+
+	project := ldtkProject{
+		Header: ldtkHeader{
+			FileType:   "LDtk Project JSON",
+			App:        "LDtk",
+			Doc:        "https://ldtk.io/json",
+			Schema:     "https://ldtk.io/files/JSON_SCHEMA.json",
+			AppAuthor:  "Sebastien 'deepnight' Benard",
+			AppVersion: LDtkVersion,
+			URL:        "https://ldtk.io",
+		},
+		IID:                 ldtkIID(filename, "project"),
+		JSONVersion:         LDtkVersion,
+		NextUID:             ldtkNextUID,
+		IdentifierStyle:     "Capitalize",
+		Toc:                 []interface{}{},
+		WorldLayout:         "Free",
+		WorldGridWidth:      tm.Columns * gridSize,
+		WorldGridHeight:     tm.Rows * gridSize,
+		DefaultLevelWidth:   tm.Columns * gridSize,
+		DefaultLevelHeight:  tm.Rows * gridSize,
+		DefaultGridSize:     gridSize,
+		DefaultEntityWidth:  gridSize,
+		DefaultEntityHeight: gridSize,
+		BgColor:             "#40465B",
+		DefaultLevelBgColor: "#696A79",
+		ImageExportMode:     "None",
+		ExportLevelBg:       true,
+		BackupLimit:         10,
+		LevelNamePattern:    "Level_%idx",
+		CustomCommands:      []interface{}{},
+		Flags:               []interface{}{},
+		Defs: ldtkDefs{
+			Layers: []ldtkLayerDef{{
+				Type:                  "Tiles",
+				Identifier:            "Tiles",
+				LayerType:             "Tiles",
+				UID:                   ldtkLayerUID,
+				GridSize:              gridSize,
+				DisplayOpacity:        1,
+				InactiveOpacity:       1,
+				CanSelectWhenInactive: true,
+				RenderInWorldView:     true,
+				ParallaxScaling:       true,
+				RequiredTags:          []string{},
+				ExcludedTags:          []string{},
+				UIFilterTags:          []string{},
+				IntGridValues:         []interface{}{},
+				IntGridValuesGroups:   []interface{}{},
+				AutoRuleGroups:        []interface{}{},
+				TilesetDefUID:         ldtkTilesetUID,
+			}},
+			Entities: []interface{}{},
+			Tilesets: []ldtkTilesetDef{{
+				CWid:            tileset.Columns,
+				CHei:            tileset.GridRows(),
+				Identifier:      "Tileset",
+				UID:             ldtkTilesetUID,
+				RelPath:         relPath,
+				PxWid:           imageWidth,
+				PxHei:           imageHeight,
+				TileGridSize:    gridSize,
+				Spacing:         tileset.Spacing,
+				Padding:         tileset.Margin,
+				Tags:            []string{},
+				EnumTags:        []interface{}{},
+				CustomData:      []interface{}{},
+				SavedSelections: []interface{}{},
+			}},
+			Enums:         []interface{}{},
+			ExternalEnums: []interface{}{},
+			LevelFields:   []interface{}{},
+		},
+		Levels: []ldtkLevel{{
+			Identifier:        "Level_0",
+			IID:               ldtkIID(filename, "level"),
+			UID:               ldtkLevelUID,
+			PxWid:             tm.Columns * gridSize,
+			PxHei:             tm.Rows * gridSize,
+			BgColorComputed:   "#696A79",
+			UseAutoIdentifier: true,
+			BgPivotX:          0.5,
+			BgPivotY:          0.5,
+			SmartColor:        "#ADADB5",
+			FieldInstances:    []interface{}{},
+			LayerInstances: []ldtkLayerInstance{{
+				Identifier:      "Tiles",
+				Type:            "Tiles",
+				CWid:            tm.Columns,
+				CHei:            tm.Rows,
+				GridSize:        gridSize,
+				Opacity:         1,
+				TilesetDefUID:   ldtkTilesetUID,
+				TilesetRelPath:  relPath,
+				IID:             ldtkIID(filename, "layer"),
+				LevelID:         ldtkLevelUID,
+				LayerDefUID:     ldtkLayerUID,
+				Visible:         true,
+				OptionalRules:   []interface{}{},
+				IntGridCsv:      []int{},
+				AutoLayerTiles:  []ldtkTile{},
+				GridTiles:       gridTiles,
+				EntityInstances: []interface{}{},
+			}},
+			Neighbours: []interface{}{},
+		}},
+		Worlds:        []interface{}{},
+		DummyWorldIID: ldtkIID(filename, "world"),
+	}
+	return writeJSON(filename, project)
+}
@@ -0,0 +1,28 @@
+package internal
+
+// PackManifest records where each packed source tile ended up in the tileset
+type PackManifest struct {
+	Image      string             `json:"image"`
+	TileWidth  int                `json:"tilewidth"`
+	TileHeight int                `json:"tileheight"`
+	Margin     int                `json:"margin"`
+	Spacing    int                `json:"spacing"`
+	Columns    int                `json:"columns"`
+	TileCount  int                `json:"tilecount"`
+	Tiles      []PackManifestTile `json:"tiles"`
+}
+
+// PackManifestTile is a tile of a source file. Column and Row are its place
+// in the source, X and Y the pixel position of its tileset tile.
+type PackManifestTile struct {
+	Filename string `json:"filename"`
+	Column   int    `json:"column"`
+	Row      int    `json:"row"`
+	Index    int    `json:"index"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+}
+
+func (m PackManifest) Write(filename string) error {
+	return writeJSON(filename, m)
+}
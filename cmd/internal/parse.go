@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 )
 
 type ParseConfig struct {
@@ -10,6 +12,31 @@ type ParseConfig struct {
 	TileHeight int
 	XOffset    int
 	YOffset    int
+	Order      string
+	ScanOrder  string
+	Tolerance  float64
+	Metric     string
+	SkipEmpty  bool
+	SkipColor  color.Color
+}
+
+// IsSkipped reports whether a crop is background that shouldn't be added to
+// the tileset: either fully transparent, or entirely the skip color
+func (ps ParseConfig) IsSkipped(crop *image.NRGBA) bool {
+	if ps.SkipEmpty && IsUniform(crop, color.Transparent) {
+		return true
+	}
+	return ps.SkipColor != nil && IsUniform(crop, ps.SkipColor)
+}
+
+// Distance measures how different two tiles are with the configured metric:
+// either the largest difference of any channel ("max", the default) or the
+// mean difference of all channels ("mean")
+func (ps ParseConfig) Distance(a, b *image.NRGBA) float64 {
+	if ps.Metric == "mean" {
+		return MeanChannelDistance(a, b)
+	}
+	return MaxChannelDistance(a, b)
 }
 
 func (ps ParseConfig) NewTilesetConfigFromParseConfig() TilesetConfig {
@@ -24,32 +51,214 @@ func (ps ParseConfig) NewTilesetConfigFromParseConfig() TilesetConfig {
 	}
 }
 
-func (ps ParseConfig) CropTiles(img *image.NRGBA) []*image.NRGBA {
+func (ps ParseConfig) GridDims(img *image.NRGBA) (columns, rows int) {
+	columns = (img.Bounds().Dx() - int(ps.XOffset)) / ps.TileWidth
+	rows = (img.Bounds().Dy() - int(ps.YOffset)) / ps.TileHeight
+	return
+}
 
-	columns := (img.Bounds().Dx() - int(ps.XOffset)) / ps.TileWidth
-	rows := (img.Bounds().Dy() - int(ps.YOffset)) / ps.TileHeight
+func (ps ParseConfig) GridPosition(location image.Point) (column, row int) {
+	column = (location.X - ps.XOffset) / ps.TileWidth
+	row = (location.Y - ps.YOffset) / ps.TileHeight
+	return
+}
 
-	crops := []*image.NRGBA{}
-	for column := 0; column < columns; column++ {
+// ScanPositions lists the column and row of every cell of the grid in the
+// order they are scanned:
+//
+//	row: left to right, then top to bottom
+//	column: top to bottom, then left to right
+//	serpentine: rows alternating left to right and right to left
+//	hilbert: along a Hilbert curve, keeping nearby cells close in the order
+func (ps ParseConfig) ScanPositions(columns, rows int) []image.Point {
+	positions := []image.Point{}
+	switch ps.ScanOrder {
+	case "column":
+		for column := 0; column < columns; column++ {
+			for row := 0; row < rows; row++ {
+				positions = append(positions, image.Pt(column, row))
+			}
+		}
+	case "serpentine":
+		for row := 0; row < rows; row++ {
+			for j := 0; j < columns; j++ {
+				column := j
+				if row%2 == 1 {
+					column = columns - 1 - j
+				}
+				positions = append(positions, image.Pt(column, row))
+			}
+		}
+	case "hilbert":
+		// Walk a curve over the smallest power of two square that covers the
+		// grid, skipping the cells outside it
+		side := 1
+		for side < columns || side < rows {
+			side *= 2
+		}
+		for d := 0; d < side*side; d++ {
+			position := hilbertPosition(side, d)
+			if position.X < columns && position.Y < rows {
+				positions = append(positions, position)
+			}
+		}
+	default:
 		for row := 0; row < rows; row++ {
-			x := (column * ps.TileWidth) + ps.XOffset
-			y := (row * ps.TileHeight) + ps.YOffset
-			min := image.Point{x, y}
-			max := image.Point{x + ps.TileWidth, y + ps.TileHeight}
-			rectangle := image.Rectangle{min, max}
+			for column := 0; column < columns; column++ {
+				positions = append(positions, image.Pt(column, row))
+			}
+		}
+	}
+	return positions
+}
 
-			crop := img.SubImage(rectangle).(*image.NRGBA)
-			crops = append(crops, crop)
+// hilbertPosition converts a distance along a Hilbert curve filling a side by
+// side square to a position in the square
+func hilbertPosition(side, d int) (position image.Point) {
+	for s := 1; s < side; s *= 2 {
+		rx := 1 & (d / 2)
+		ry := 1 & (d ^ rx)
+		if ry == 0 {
+			if rx == 1 {
+				position.X = s - 1 - position.X
+				position.Y = s - 1 - position.Y
+			}
+			position.X, position.Y = position.Y, position.X
 		}
+		position.X += s * rx
+		position.Y += s * ry
+		d /= 4
+	}
+	return
+}
+
+func (ps ParseConfig) CropTiles(img *image.NRGBA) []*image.NRGBA {
+
+	columns, rows := ps.GridDims(img)
+
+	crops := []*image.NRGBA{}
+	for _, position := range ps.ScanPositions(columns, rows) {
+		x := (position.X * ps.TileWidth) + ps.XOffset
+		y := (position.Y * ps.TileHeight) + ps.YOffset
+		min := image.Point{x, y}
+		max := image.Point{x + ps.TileWidth, y + ps.TileHeight}
+		rectangle := image.Rectangle{min, max}
+
+		crop := img.SubImage(rectangle).(*image.NRGBA)
+		crops = append(crops, crop)
 	}
 
 	return crops
 }
 
+func (ps ParseConfig) NewTileMap(img *image.NRGBA, occurrences []TileOccurrence) TileMap {
+	columns, rows := ps.GridDims(img)
+	tileMap := NewTileMap(columns, rows, ps.TileWidth, ps.TileHeight)
+	for _, occurrence := range occurrences {
+		column, row := ps.GridPosition(occurrence.Location)
+		tileMap.SetCell(column, row, MapCell{Index: occurrence.Index, Flip: occurrence.Flip})
+	}
+	return tileMap
+}
+
 type FrequencyTile struct {
-	Hash            string
-	Image           *image.NRGBA
-	Count           int
-	FirstLocation   image.Point
-	Transformations bool
+	Hash          string
+	Image         *image.NRGBA
+	Count         int
+	FirstSource   int
+	FirstLocation image.Point
+	Occurrences   []TileOccurrence
+	MaxError      float64
+}
+
+// Transformations lists the distinct transformations, other than the
+// identity, that were needed to match occurrences of the tile, in the order
+// they were first needed
+func (ft FrequencyTile) Transformations() []string {
+	seen := map[string]bool{}
+	transformations := []string{}
+	for _, occurrence := range ft.Occurrences {
+		if occurrence.Transformation == IdentityTransformation || seen[occurrence.Transformation] {
+			continue
+		}
+		seen[occurrence.Transformation] = true
+		transformations = append(transformations, occurrence.Transformation)
+	}
+	return transformations
+}
+
+// TileOccurrence is a single crop of a parsed image, identified by the index
+// of the image among those parsed together. Transformation is the
+// one that turns the crop into the tileset tile at Index, and Flip is how
+// that tileset tile is drawn to get the crop back. Error is how far the crop
+// is from the tileset tile when it was matched within a tolerance.
+type TileOccurrence struct {
+	Source         int
+	Location       image.Point
+	Index          int
+	Transformation string
+	Flip           Flip
+	Error          float64
+}
+
+type MapCell struct {
+	Index int
+	Flip  Flip
+}
+
+// TileMap is the grid of tileset indices that recreates a parsed image. Cells
+// are stored row-major and an index of -1 marks an empty cell.
+type TileMap struct {
+	Columns    int
+	Rows       int
+	TileWidth  int
+	TileHeight int
+	Cells      []MapCell
+}
+
+func NewTileMap(columns, rows, tileWidth, tileHeight int) TileMap {
+	cells := make([]MapCell, columns*rows)
+	for j := range cells {
+		cells[j].Index = -1
+	}
+	return TileMap{
+		Columns:    columns,
+		Rows:       rows,
+		TileWidth:  tileWidth,
+		TileHeight: tileHeight,
+		Cells:      cells,
+	}
+}
+
+func (tm *TileMap) Cell(column, row int) MapCell {
+	return tm.Cells[(row*tm.Columns)+column]
+}
+
+func (tm *TileMap) SetCell(column, row int, cell MapCell) {
+	tm.Cells[(row*tm.Columns)+column] = cell
+}
+
+// Render draws every cell of the map with its tileset tile. Empty cells are
+// left as the background color.
+func (tm *TileMap) Render(tileset *TilesetConfig, bgColor color.Color) (*image.NRGBA, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, tm.Columns*tm.TileWidth, tm.Rows*tm.TileHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bgColor), image.Point{}, draw.Src)
+
+	for row := 0; row < tm.Rows; row++ {
+		for column := 0; column < tm.Columns; column++ {
+			cell := tm.Cell(column, row)
+			if cell.Index < 0 {
+				continue
+			}
+			if cell.Index >= len(tileset.TileImages) {
+				return nil, fmt.Errorf("cell %d,%d: index %d is outside the tileset of %d tiles", column, row, cell.Index, len(tileset.TileImages))
+			}
+			tileImage := cell.Flip.Apply(tileset.TileImages[cell.Index])
+			pos := image.Pt(column*tm.TileWidth, row*tm.TileHeight)
+			rect := image.Rectangle{pos, pos.Add(tileImage.Bounds().Size())}
+			draw.Draw(img, rect, tileImage, tileImage.Bounds().Min, draw.Src)
+		}
+	}
+
+	return img, nil
 }
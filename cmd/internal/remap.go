@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+)
+
+// RemapEntry moves a tile from its old index to its new one. Transformation
+// turns the old tile into the new one, and Flags are the flips that draw the
+// old tile from the new one, packed as in flags sidecars.
+type RemapEntry struct {
+	Old            int    `json:"old"`
+	New            int    `json:"new"`
+	Transformation string `json:"transformation"`
+	Flags          uint8  `json:"flags"`
+}
+
+// Remap is the table of new indices for the tiles of a source tileset
+type Remap struct {
+	Source  string       `json:"source"`
+	Tileset string       `json:"tileset"`
+	Tiles   []RemapEntry `json:"tiles"`
+}
+
+// NewRemap makes the remap of a source's tiles from their occurrences
+func NewRemap(source, tileset string, occurrences []TileOccurrence) Remap {
+	remap := Remap{Source: source, Tileset: tileset, Tiles: make([]RemapEntry, len(occurrences))}
+	for j, occurrence := range occurrences {
+		remap.Tiles[j] = RemapEntry{
+			Old:            j,
+			New:            occurrence.Index,
+			Transformation: occurrence.Transformation,
+			Flags:          occurrence.Flip.FlagBits(),
+		}
+	}
+	return remap
+}
+
+func (r Remap) WriteJSON(filename string) error {
+	return writeJSON(filename, r)
+}
+
+// WriteCSV writes a header, then one row per source tile
+func (r Remap) WriteCSV(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"old", "new", "transformation", "flags"}); err != nil {
+		return err
+	}
+	for _, entry := range r.Tiles {
+		record := []string{
+			strconv.Itoa(entry.Old),
+			strconv.Itoa(entry.New),
+			entry.Transformation,
+			strconv.Itoa(int(entry.Flags)),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,377 @@
+package internal
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const TiledVersion = "1.10"
+
+type tsxImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+type tsxTileset struct {
+	XMLName    xml.Name `xml:"tileset"`
+	Version    string   `xml:"version,attr"`
+	Name       string   `xml:"name,attr"`
+	TileWidth  int      `xml:"tilewidth,attr"`
+	TileHeight int      `xml:"tileheight,attr"`
+	Spacing    int      `xml:"spacing,attr"`
+	Margin     int      `xml:"margin,attr"`
+	TileCount  int      `xml:"tilecount,attr"`
+	Columns    int      `xml:"columns,attr"`
+	Image      tsxImage `xml:"image"`
+}
+
+type tmxTilesetRef struct {
+	FirstGID int    `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type tmxData struct {
+	Encoding string `xml:"encoding,attr"`
+	Text     string `xml:",innerxml"`
+}
+
+type tmxLayer struct {
+	ID     int     `xml:"id,attr"`
+	Name   string  `xml:"name,attr"`
+	Width  int     `xml:"width,attr"`
+	Height int     `xml:"height,attr"`
+	Data   tmxData `xml:"data"`
+}
+
+type tmxMap struct {
+	XMLName      xml.Name      `xml:"map"`
+	Version      string        `xml:"version,attr"`
+	Orientation  string        `xml:"orientation,attr"`
+	RenderOrder  string        `xml:"renderorder,attr"`
+	Width        int           `xml:"width,attr"`
+	Height       int           `xml:"height,attr"`
+	TileWidth    int           `xml:"tilewidth,attr"`
+	TileHeight   int           `xml:"tileheight,attr"`
+	Infinite     int           `xml:"infinite,attr"`
+	NextLayerID  int           `xml:"nextlayerid,attr"`
+	NextObjectID int           `xml:"nextobjectid,attr"`
+	Tileset      tmxTilesetRef `xml:"tileset"`
+	Layer        tmxLayer      `xml:"layer"`
+}
+
+type tsjTileset struct {
+	Columns     int    `json:"columns"`
+	Image       string `json:"image"`
+	ImageHeight int    `json:"imageheight"`
+	ImageWidth  int    `json:"imagewidth"`
+	Margin      int    `json:"margin"`
+	Name        string `json:"name"`
+	Spacing     int    `json:"spacing"`
+	TileCount   int    `json:"tilecount"`
+	TileHeight  int    `json:"tileheight"`
+	TileWidth   int    `json:"tilewidth"`
+	Type        string `json:"type"`
+	Version     string `json:"version"`
+}
+
+type tmjTilesetRef struct {
+	FirstGID int    `json:"firstgid"`
+	Source   string `json:"source"`
+}
+
+type tmjLayer struct {
+	Data    []uint32 `json:"data"`
+	Height  int      `json:"height"`
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Opacity float64  `json:"opacity"`
+	Type    string   `json:"type"`
+	Visible bool     `json:"visible"`
+	Width   int      `json:"width"`
+	X       int      `json:"x"`
+	Y       int      `json:"y"`
+}
+
+type tmjMap struct {
+	CompressionLevel int             `json:"compressionlevel"`
+	Height           int             `json:"height"`
+	Infinite         bool            `json:"infinite"`
+	Layers           []tmjLayer      `json:"layers"`
+	NextLayerID      int             `json:"nextlayerid"`
+	NextObjectID     int             `json:"nextobjectid"`
+	Orientation      string          `json:"orientation"`
+	RenderOrder      string          `json:"renderorder"`
+	TileHeight       int             `json:"tileheight"`
+	Tilesets         []tmjTilesetRef `json:"tilesets"`
+	TileWidth        int             `json:"tilewidth"`
+	Type             string          `json:"type"`
+	Version          string          `json:"version"`
+	Width            int             `json:"width"`
+}
+
+// GID converts a cell to a Tiled global tile id for a tileset with a
+// firstgid of 1. Empty cells are 0.
+func (c MapCell) GID() uint32 {
+	if c.Index < 0 {
+		return 0
+	}
+	return uint32(c.Index+1) | c.Flip.Bits()
+}
+
+// CellFromGID converts a Tiled global tile id back to a cell of the tileset
+// with the given firstgid
+func CellFromGID(gid uint32, firstGID int) MapCell {
+	id := gid &^ FlipFlags
+	if id == 0 {
+		return MapCell{Index: -1}
+	}
+	return MapCell{Index: int(id) - firstGID, Flip: FlipFromBits(gid)}
+}
+
+func (tm *TileMap) GIDs() []uint32 {
+	gids := make([]uint32, len(tm.Cells))
+	for j, cell := range tm.Cells {
+		gids[j] = cell.GID()
+	}
+	return gids
+}
+
+func (tm *TileMap) gidCSV() string {
+	var sb strings.Builder
+	sb.WriteString("\n")
+	gids := tm.GIDs()
+	for row := 0; row < tm.Rows; row++ {
+		for column := 0; column < tm.Columns; column++ {
+			sb.WriteString(fmt.Sprintf("%d", gids[(row*tm.Columns)+column]))
+			if row < tm.Rows-1 || column < tm.Columns-1 {
+				sb.WriteString(",")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// RelativePath returns target relative to the directory containing from,
+// which is how Tiled references external files
+func RelativePath(from, target string) string {
+	rel, err := filepath.Rel(filepath.Dir(from), target)
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
+
+func writeXML(filename string, v interface{}) error {
+	out, err := xml.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
+	}
+	content := []byte(xml.Header)
+	content = append(content, out...)
+	content = append(content, '\n')
+	return os.WriteFile(filename, content, 0644)
+}
+
+func (tm *TileMap) setGIDs(gids []uint32, firstGID int) error {
+	if len(gids) != len(tm.Cells) {
+		return fmt.Errorf("map has %d tiles, expected %d for %dx%d", len(gids), len(tm.Cells), tm.Columns, tm.Rows)
+	}
+	for j, gid := range gids {
+		tm.Cells[j] = CellFromGID(gid, firstGID)
+	}
+	return nil
+}
+
+func writeJSON(filename string, v interface{}) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	return os.WriteFile(filename, out, 0644)
+}
+
+func (ts *TilesetConfig) WriteTSX(filename, imageFilename string) error {
+	width, height := ts.Dims()
+	tileset := tsxTileset{
+		Version:    TiledVersion,
+		Name:       strings.TrimSuffix(filepath.Base(imageFilename), filepath.Ext(imageFilename)),
+		TileWidth:  ts.TileWidth,
+		TileHeight: ts.TileHeight,
+		Spacing:    ts.Spacing,
+		Margin:     ts.Margin,
+		TileCount:  len(ts.TileImages),
+		Columns:    ts.Columns,
+		Image: tsxImage{
+			Source: RelativePath(filename, imageFilename),
+			Width:  width,
+			Height: height,
+		},
+	}
+	return writeXML(filename, tileset)
+}
+
+func (tm *TileMap) WriteTMX(filename, tilesetFilename string) error {
+	tmx := tmxMap{
+		Version:      TiledVersion,
+		Orientation:  "orthogonal",
+		RenderOrder:  "right-down",
+		Width:        tm.Columns,
+		Height:       tm.Rows,
+		TileWidth:    tm.TileWidth,
+		TileHeight:   tm.TileHeight,
+		Infinite:     0,
+		NextLayerID:  2,
+		NextObjectID: 1,
+		Tileset: tmxTilesetRef{
+			FirstGID: 1,
+			Source:   RelativePath(filename, tilesetFilename),
+		},
+		Layer: tmxLayer{
+			ID:     1,
+			Name:   "Tile Layer 1",
+			Width:  tm.Columns,
+			Height: tm.Rows,
+			Data: tmxData{
+				Encoding: "csv",
+				Text:     tm.gidCSV(),
+			},
+		},
+	}
+	return writeXML(filename, tmx)
+}
+
+func (ts *TilesetConfig) WriteTSJ(filename, imageFilename string) error {
+	width, height := ts.Dims()
+	tileset := tsjTileset{
+		Columns:     ts.Columns,
+		Image:       RelativePath(filename, imageFilename),
+		ImageHeight: height,
+		ImageWidth:  width,
+		Margin:      ts.Margin,
+		Name:        strings.TrimSuffix(filepath.Base(imageFilename), filepath.Ext(imageFilename)),
+		Spacing:     ts.Spacing,
+		TileCount:   len(ts.TileImages),
+		TileHeight:  ts.TileHeight,
+		TileWidth:   ts.TileWidth,
+		Type:        "tileset",
+		Version:     TiledVersion,
+	}
+	return writeJSON(filename, tileset)
+}
+
+func (tm *TileMap) WriteTMJ(filename, tilesetFilename string) error {
+	tmj := tmjMap{
+		CompressionLevel: -1,
+		Height:           tm.Rows,
+		Infinite:         false,
+		Layers: []tmjLayer{{
+			Data:    tm.GIDs(),
+			Height:  tm.Rows,
+			ID:      1,
+			Name:    "Tile Layer 1",
+			Opacity: 1,
+			Type:    "tilelayer",
+			Visible: true,
+			Width:   tm.Columns,
+		}},
+		NextLayerID:  2,
+		NextObjectID: 1,
+		Orientation:  "orthogonal",
+		RenderOrder:  "right-down",
+		TileHeight:   tm.TileHeight,
+		Tilesets: []tmjTilesetRef{{
+			FirstGID: 1,
+			Source:   RelativePath(filename, tilesetFilename),
+		}},
+		TileWidth: tm.TileWidth,
+		Type:      "map",
+		Version:   TiledVersion,
+		Width:     tm.Columns,
+	}
+	return writeJSON(filename, tmj)
+}
+
+func ReadTMX(filename string) (TileMap, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return TileMap{}, err
+	}
+	var tmx tmxMap
+	if err := xml.Unmarshal(content, &tmx); err != nil {
+		return TileMap{}, fmt.Errorf("error reading tmx: %s", err.Error())
+	}
+	if tmx.Layer.Data.Encoding != "csv" {
+		return TileMap{}, fmt.Errorf("unsupported tmx layer encoding: %q", tmx.Layer.Data.Encoding)
+	}
+
+	gids := []uint32{}
+	for _, field := range strings.Split(tmx.Layer.Data.Text, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		gid, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return TileMap{}, fmt.Errorf("error reading tmx layer data: %s", err.Error())
+		}
+		gids = append(gids, uint32(gid))
+	}
+
+	tileMap := NewTileMap(tmx.Layer.Width, tmx.Layer.Height, tmx.TileWidth, tmx.TileHeight)
+	if err := tileMap.setGIDs(gids, tmx.Tileset.FirstGID); err != nil {
+		return TileMap{}, err
+	}
+	return tileMap, nil
+}
+
+func ReadTMJ(filename string) (TileMap, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return TileMap{}, err
+	}
+	var tmj tmjMap
+	if err := json.Unmarshal(content, &tmj); err != nil {
+		return TileMap{}, fmt.Errorf("error reading tmj: %s", err.Error())
+	}
+	if len(tmj.Layers) == 0 || len(tmj.Tilesets) == 0 {
+		return TileMap{}, fmt.Errorf("tmj must have a tile layer and a tileset")
+	}
+
+	layer := tmj.Layers[0]
+	tileMap := NewTileMap(layer.Width, layer.Height, tmj.TileWidth, tmj.TileHeight)
+	if err := tileMap.setGIDs(layer.Data, tmj.Tilesets[0].FirstGID); err != nil {
+		return TileMap{}, err
+	}
+	return tileMap, nil
+}
+
+// ReadTilesetMetadata reads the columns and tile count of a tsx or tsj tileset
+func ReadTilesetMetadata(filename string) (columns, tileCount int, err error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tsx":
+		var tsx tsxTileset
+		if err := xml.Unmarshal(content, &tsx); err != nil {
+			return 0, 0, fmt.Errorf("error reading tsx: %s", err.Error())
+		}
+		return tsx.Columns, tsx.TileCount, nil
+	case ".tsj":
+		var tsj tsjTileset
+		if err := json.Unmarshal(content, &tsj); err != nil {
+			return 0, 0, fmt.Errorf("error reading tsj: %s", err.Error())
+		}
+		return tsj.Columns, tsj.TileCount, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported tileset metadata format: %s", filename)
+}
@@ -16,6 +16,10 @@ type TilesetConfig struct {
 	Columns    int
 	Color      color.Color
 	TileImages []*image.NRGBA
+	// The image is padded to a multiple of Align, then to a power of two
+	// when Pot is set
+	Align int
+	Pot   bool
 }
 
 func NewDefaultTilesetConfig() TilesetConfig {
@@ -30,10 +34,10 @@ func NewDefaultTilesetConfig() TilesetConfig {
 	}
 }
 
-func NewTilesetConfig(tileSize, margin, spacing int, bgColor color.Color) TilesetConfig {
+func NewTilesetConfig(tileWidth, tileHeight, margin, spacing int, bgColor color.Color) TilesetConfig {
 	return TilesetConfig{
-		TileWidth:  tileSize,
-		TileHeight: tileSize,
+		TileWidth:  tileWidth,
+		TileHeight: tileHeight,
 		Margin:     margin,
 		Spacing:    spacing,
 		Columns:    10,
@@ -54,10 +58,41 @@ func (ts *TilesetConfig) TileRectangle(row, column int) (rect image.Rectangle) {
 	return
 }
 
+// ContentDims is the size of the tileset image without padding
+func (ts *TilesetConfig) ContentDims() (width, height int) {
+	width = ts.widthForColumns(ts.Columns)
+	height = ts.heightForRows(ts.Rows())
+	return
+}
+
 func (ts *TilesetConfig) Dims() (width, height int) {
-	rows := ts.Rows()
-	width = (ts.Columns * (ts.TileWidth + ts.Spacing)) - ts.Spacing + (2 * ts.Margin)
-	height = (rows * (ts.TileHeight + ts.Spacing)) - ts.Spacing + (2 * ts.Margin)
+	return ts.dimsForColumns(ts.Columns)
+}
+
+// padDimension rounds an image dimension up to a multiple of align, then to
+// a power of two when pot is set
+func padDimension(v, align int, pot bool) int {
+	if align > 1 {
+		v = ((v + align - 1) / align) * align
+	}
+	if pot {
+		padded := 1
+		for padded < v {
+			padded *= 2
+		}
+		v = padded
+	}
+	return v
+}
+
+func (ts *TilesetConfig) pad(v int) int {
+	return padDimension(v, ts.Align, ts.Pot)
+}
+
+func (ts *TilesetConfig) dimsForColumns(columns int) (width, height int) {
+	rows := (len(ts.TileImages) + columns - 1) / columns
+	width = ts.pad(ts.widthForColumns(columns))
+	height = ts.pad(ts.heightForRows(rows))
 	return
 }
 
@@ -129,3 +164,107 @@ func (ts *TilesetConfig) ToImage() *image.NRGBA {
 
 	return tilesetImage
 }
+
+// SheetLayout chooses how many columns a tileset image has and how it's
+// padded. Zero values are unset, and the columns the tileset already has are
+// kept unless the image is padded.
+type SheetLayout struct {
+	Columns  int
+	Rows     int
+	MaxWidth int
+	Square   bool
+	Align    int
+	Pot      bool
+}
+
+func (ts *TilesetConfig) widthForColumns(columns int) int {
+	return (columns * (ts.TileWidth + ts.Spacing)) - ts.Spacing + (2 * ts.Margin)
+}
+
+func (ts *TilesetConfig) heightForRows(rows int) int {
+	return (rows * (ts.TileHeight + ts.Spacing)) - ts.Spacing + (2 * ts.Margin)
+}
+
+// bestColumns finds the columns that make the most square image, or the
+// smallest image when not square, no wider than maxWidth
+func (ts *TilesetConfig) bestColumns(maxWidth int, square bool) int {
+	count := len(ts.TileImages)
+	best, bestSide, bestArea := 1, 0, 0
+	for columns := 1; columns <= count; columns++ {
+		width, height := ts.dimsForColumns(columns)
+		if maxWidth > 0 && width > maxWidth && columns > 1 {
+			break
+		}
+		side := width
+		if height > side {
+			side = height
+		}
+		area := width * height
+		better := side < bestSide || (side == bestSide && area < bestArea)
+		if !square {
+			better = area < bestArea || (area == bestArea && side < bestSide)
+		}
+		if bestSide == 0 || better {
+			best, bestSide, bestArea = columns, side, area
+		}
+	}
+	return best
+}
+
+// Reflow sets the columns and padding of the tileset from the layout
+func (ts *TilesetConfig) Reflow(layout SheetLayout) error {
+	ts.Align = layout.Align
+	ts.Pot = layout.Pot
+
+	count := len(ts.TileImages)
+	if count == 0 {
+		return nil
+	}
+	switch {
+	case layout.Columns > 0:
+		ts.Columns = layout.Columns
+	case layout.Rows > 0:
+		ts.Columns = (count + layout.Rows - 1) / layout.Rows
+	case layout.Square:
+		ts.Columns = ts.bestColumns(layout.MaxWidth, true)
+	case layout.Pot || layout.Align > 1:
+		ts.Columns = ts.bestColumns(layout.MaxWidth, false)
+	}
+
+	if width, _ := ts.Dims(); layout.MaxWidth > 0 && width > layout.MaxWidth {
+		if layout.Columns > 0 || layout.Rows > 0 {
+			return fmt.Errorf("%d columns of tiles are %d pixels wide, wider than the max width of %d",
+				ts.Columns, width, layout.MaxWidth)
+		}
+		for ts.Columns > 1 && width > layout.MaxWidth {
+			ts.Columns--
+			width, _ = ts.Dims()
+		}
+		if width > layout.MaxWidth {
+			return fmt.Errorf("a single column of tiles is %d pixels wide, wider than the max width of %d",
+				width, layout.MaxWidth)
+		}
+	}
+
+	// Tiled, LDtk and engines work out the columns from the image width, so
+	// the tiles are laid out in every column that fits in the padding
+	width, _ := ts.Dims()
+	for columns := ts.columnsForWidth(width); columns > ts.Columns; columns-- {
+		if padded, _ := ts.dimsForColumns(columns); padded == width {
+			ts.Columns = columns
+			break
+		}
+	}
+	return nil
+}
+
+func (ts *TilesetConfig) columnsForWidth(width int) int {
+	return (width - (2 * ts.Margin) + ts.Spacing) / (ts.TileWidth + ts.Spacing)
+}
+
+// GridRows is the number of rows of tiles that fit in the tileset image,
+// which can be more than the rows in use when the image is padded
+func (ts *TilesetConfig) GridRows() int {
+	_, height := ts.Dims()
+	return (height - (2 * ts.Margin) + ts.Spacing) / (ts.TileHeight + ts.Spacing)
+}
@@ -1,6 +1,10 @@
 package internal
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 func ValidatePixelValue(v int) error {
 	if v < 0 || v > 65535 {
@@ -15,3 +19,34 @@ func ValidatePositivePixelValue(v int) error {
 	}
 	return nil
 }
+
+// ParseTileSize reads a tile size given either as a single value for square
+// tiles or as WxH
+func ParseTileSize(size string) (width, height int, err error) {
+	parts := strings.Split(strings.ToLower(size), "x")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("size must be a single value or WxH")
+	}
+	values := make([]int, len(parts))
+	for j, part := range parts {
+		values[j], err = strconv.Atoi(part)
+		if err != nil {
+			return 0, 0, fmt.Errorf("size must be a single value or WxH")
+		}
+		if err = ValidatePositivePixelValue(values[j]); err != nil {
+			return 0, 0, err
+		}
+	}
+	width = values[0]
+	height = values[len(values)-1]
+	return
+}
+
+func ValidateChoice(v string, choices []string) error {
+	for _, choice := range choices {
+		if v == choice {
+			return nil
+		}
+	}
+	return fmt.Errorf("value must be one of: %s", strings.Join(choices, ", "))
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var mergeCmd *cobra.Command
+
+var remapFormat string
+
+var remapFormats = []string{"json", "csv"}
+
+const validRemapFormatsMessage = "Valid formats are: \"json\" and \"csv\"."
+
+// parseMergeInput reads an input given as filename@size,margin,spacing. Any
+// of the layout values can be left out to use the global flags.
+func parseMergeInput(arg string, defaults i.TilesetConfig) (filename string, tileset i.TilesetConfig, err error) {
+	tileset = defaults
+	tileset.TileImages = nil
+	at := strings.LastIndex(arg, "@")
+	if _, statErr := os.Stat(arg); statErr == nil || at < 0 {
+		return arg, tileset, nil
+	}
+	filename = arg[:at]
+
+	values := strings.Split(arg[at+1:], ",")
+	if len(values) > 3 {
+		return "", tileset, fmt.Errorf("%s: layout must be size,margin,spacing", arg)
+	}
+	if values[0] != "" {
+		if tileset.TileWidth, tileset.TileHeight, err = i.ParseTileSize(values[0]); err != nil {
+			return "", tileset, fmt.Errorf("%s: size: %s", arg, err.Error())
+		}
+	}
+	for j, name := range []string{"margin", "spacing"} {
+		if len(values) <= j+1 || values[j+1] == "" {
+			continue
+		}
+		v, convErr := strconv.Atoi(values[j+1])
+		if convErr == nil {
+			convErr = i.ValidatePixelValue(v)
+		}
+		if convErr != nil {
+			return "", tileset, fmt.Errorf("%s: %s must be in range [0, 65535]", arg, name)
+		}
+		if name == "margin" {
+			tileset.Margin = v
+		} else {
+			tileset.Spacing = v
+		}
+	}
+	return filename, tileset, nil
+}
+
+// readTilesetTiles reads the tiles of each tileset image, one list of tiles
+// per source
+func readTilesetTiles(imgs []*image.NRGBA, tilesets []i.TilesetConfig, filenames []string) ([][]*image.NRGBA, error) {
+	tiles := make([][]*image.NRGBA, len(imgs))
+	for j, img := range imgs {
+		if err := tilesets[j].ReadImage(img); err != nil {
+			return nil, fmt.Errorf("%s: %s", filenames[j], err.Error())
+		}
+		tiles[j] = tilesets[j].TileImages
+	}
+	return tiles, nil
+}
+
+// writeRemaps writes the remap table of each source next to the output
+func writeRemaps(filenames []string, occurrences [][]i.TileOccurrence, verbose bool) {
+	for j, filename := range filenames {
+		remapFilename := mapFilename(filename, ".remap."+remapFormat)
+		remap := i.NewRemap(filename, i.RelativePath(remapFilename, Output), occurrences[j])
+		if verbose {
+			fmt.Printf("Saving remap of %s to %s\n", filename, remapFilename)
+		}
+		switch remapFormat {
+		case "csv":
+			exitOnSaveError(remap.WriteCSV(remapFilename))
+		default:
+			exitOnSaveError(remap.WriteJSON(remapFilename))
+		}
+	}
+}
+
+func init() {
+
+	mergeCmd = &cobra.Command{
+		Use:   "merge <filename[@size,margin,spacing]>...",
+		Short: "Merge tilesets into one.",
+		Long:  "The merge command combines several tilesets into one, keeping each distinct tile once in the order the tiles are first seen. Each tileset is read with the size, margin and spacing flags, or with its own layout given after an @, for example tiles.png@16x8,1,2. Any of the layout values can be left out to use the flags. Every tileset must have the same tile size. With transform, tiles that are flips or rotations of each other are merged too. A remap table is written for each input, named after it, giving the merged index of each of its tiles and the flips that draw the original tile, so existing maps can be rewritten.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				fmt.Fprintln(os.Stderr, "At least one arg required: <filename[@size,margin,spacing]>...")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool merge --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if err := i.ValidatePixelValue(outMargin); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid out-margin: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidatePixelValue(outSpacing); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid out-spacing: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(remapFormat, remapFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid remap: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(metadataFormat, metadataFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid metadata: %s\n", err.Error())
+				os.Exit(1)
+			}
+			validateSheetLayout()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filenames := make([]string, len(args))
+			tilesets := make([]i.TilesetConfig, len(args))
+			for j, arg := range args {
+				var err error
+				filenames[j], tilesets[j], err = parseMergeInput(arg, tc)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid input: %s\n", err.Error())
+					os.Exit(1)
+				}
+				if tilesets[j].TileWidth != tilesets[0].TileWidth || tilesets[j].TileHeight != tilesets[0].TileHeight {
+					fmt.Fprintf(os.Stderr, "Error: %s has %dx%d tiles but %s has %dx%d tiles\n",
+						filenames[j], tilesets[j].TileWidth, tilesets[j].TileHeight,
+						filenames[0], tilesets[0].TileWidth, tilesets[0].TileHeight)
+					os.Exit(1)
+				}
+			}
+			if err := validateMapFilenames(filenames); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			imgs := make([]*image.NRGBA, len(filenames))
+			for j, filename := range filenames {
+				imgs[j] = i.Open(filename, Verbose)
+			}
+			tiles, err := readTilesetTiles(imgs, tilesets, filenames)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading tileset: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			tileWidth, tileHeight := tilesets[0].TileWidth, tilesets[0].TileHeight
+			var transformations []string
+			if transform {
+				transformations = CreateTransformations()
+				if tileWidth != tileHeight {
+					transformations = flipOnlyTransformations(transformations)
+				}
+			}
+			parseConfig := i.ParseConfig{TileWidth: tileWidth, TileHeight: tileHeight, Order: "first-seen"}
+			frequencyTiles, occurrences := computeFreq(nil, tiles, transformations, parseConfig)
+
+			if Verbose {
+				total := 0
+				for _, sourceTiles := range tiles {
+					total += len(sourceTiles)
+				}
+				fmt.Printf("Merged %d tiles from %d tilesets into %d unique tiles\n", total, len(imgs), len(frequencyTiles))
+			}
+
+			outTc := i.NewTilesetConfig(tileWidth, tileHeight, outMargin, outSpacing, BgColor)
+			for _, frequencyTile := range frequencyTiles {
+				outTc.TileImages = append(outTc.TileImages, frequencyTile.Image)
+			}
+			reflow(&outTc, Verbose)
+
+			tilesetImage := outTc.ToImage()
+			i.Save(tilesetImage, Output, Verbose)
+
+			if metadataFormat != "none" {
+				writeTilesetMetadata(metadataFormat, outTc, Verbose)
+			}
+			writeRemaps(filenames, occurrences, Verbose)
+		},
+	}
+	mergeCmd.Flags().IntVar(&outMargin, "out-margin", 0, "output tileset margin in pixels (default 0)")
+	mergeCmd.Flags().IntVar(&outSpacing, "out-spacing", 0, "output tile spacing in pixels (default 0)")
+	mergeCmd.Flags().BoolVarP(&transform, "transform", "t", false, "merge tiles that are flips or rotations of each other. Non-square tiles are only flipped and rotated by 180 degrees (default false)")
+	mergeCmd.Flags().StringVar(&remapFormat, "remap", "json", fmt.Sprintf("remap table format. %s", validRemapFormatsMessage))
+	mergeCmd.Flags().StringVar(&metadataFormat, "metadata", "none", fmt.Sprintf("tileset metadata format to write alongside the tileset. %s", validMetadataFormatsMessage))
+	addSheetLayoutFlags(mergeCmd)
+}
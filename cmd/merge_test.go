@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/disintegration/imaging"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestParseMergeInput(t *testing.T) {
+
+	defaults := i.NewTilesetConfig(testTileSize, testTileSize, 1, 2, color.Transparent)
+	tests := map[string][]int{
+		"tiles.png":          {testTileSize, testTileSize, 1, 2},
+		"tiles.png@8":        {8, 8, 1, 2},
+		"tiles.png@16x8,3":   {16, 8, 3, 2},
+		"tiles.png@,0,0":     {testTileSize, testTileSize, 0, 0},
+		"tiles.png@32,4,6":   {32, 32, 4, 6},
+		"tiles.png@16x8,,10": {16, 8, 1, 10},
+	}
+	for arg, expected := range tests {
+		filename, tileset, err := parseMergeInput(arg, defaults)
+		if err != nil {
+			t.Errorf("%s: %s", arg, err.Error())
+			continue
+		}
+		got := []int{tileset.TileWidth, tileset.TileHeight, tileset.Margin, tileset.Spacing}
+		if filename != "tiles.png" || got[0] != expected[0] || got[1] != expected[1] || got[2] != expected[2] || got[3] != expected[3] {
+			t.Errorf("%s: expected tiles.png with %v, got %s with %v", arg, expected, filename, got)
+		}
+	}
+
+	for _, arg := range []string{"tiles.png@x", "tiles.png@16,-1", "tiles.png@16,1,2,3"} {
+		if _, _, err := parseMergeInput(arg, defaults); err == nil {
+			t.Errorf("%s: expected an error", arg)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	// A tileset, and the same tileset with every tile flipped and respaced
+	img := i.Open("../fixtures/test_02.png", false)
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	tileset.ReadImage(img)
+	flipped := i.NewTilesetConfig(testTileSize, testTileSize, 1, 2, color.Transparent)
+	flipped.Columns = tileset.Columns
+	for _, tileImage := range tileset.TileImages {
+		flipped.TileImages = append(flipped.TileImages, imaging.FlipH(tileImage))
+	}
+	flippedImg := flipped.ToImage()
+
+	imgs := []*image.NRGBA{img, flippedImg}
+	tilesets := []i.TilesetConfig{
+		i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent),
+		i.NewTilesetConfig(testTileSize, testTileSize, 1, 2, color.Transparent),
+	}
+	tiles, err := readTilesetTiles(imgs, tilesets, []string{"tileset.png", "flipped.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize, Order: "first-seen"}
+	frequencyTiles, occurrences := computeFreq(nil, tiles, CreateTransformations(), parseConfig)
+	unique := map[string]bool{}
+	for _, tileImage := range tileset.TileImages {
+		unique[hashNrgba(tileImage)] = true
+	}
+	if len(frequencyTiles) != len(unique) {
+		t.Errorf("expected %d unique tiles, got %d", len(unique), len(frequencyTiles))
+	}
+
+	remap := i.NewRemap("flipped.png", "merged.png", occurrences[1])
+	for j, entry := range remap.Tiles {
+		drawn := i.FlipFromBits(uint32(entry.Flags) << 29).Apply(frequencyTiles[entry.New].Image)
+		if hashNrgba(drawn) != hashNrgba(tiles[1][j]) {
+			t.Errorf("tile %d: merged tile %d with flags %d doesn't draw the original", j, entry.New, entry.Flags)
+		}
+	}
+}
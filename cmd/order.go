@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"sort"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var tileOrders = []string{"frequency", "first-seen", "scanline", "column-major", "luminance", "hue", "similarity"}
+
+const validOrdersMessage = "Valid orders are: \"frequency\" (most used first), \"first-seen\" (in scan order), \"scanline\" (first seen left to right, then top to bottom), \"column-major\" (first seen top to bottom, then left to right), \"luminance\" (dark to light), \"hue\" (by average color, then dark to light) and \"similarity\" (each tile followed by the most similar remaining tile)."
+
+// firstSeenLess orders tiles by the image they were first seen in, then by
+// where in that image
+func firstSeenLess(a, b i.FrequencyTile, columnMajor bool) bool {
+	if a.FirstSource != b.FirstSource {
+		return a.FirstSource < b.FirstSource
+	}
+	if columnMajor && a.FirstLocation.X != b.FirstLocation.X {
+		return a.FirstLocation.X < b.FirstLocation.X
+	}
+	if a.FirstLocation.Y != b.FirstLocation.Y {
+		return a.FirstLocation.Y < b.FirstLocation.Y
+	}
+	return a.FirstLocation.X < b.FirstLocation.X
+}
+
+// orderTiles sorts tiles in place. Every order is deterministic: ties are
+// broken by where the tiles were first seen.
+func orderTiles(frequencyTiles []i.FrequencyTile, order string) {
+	switch order {
+	case "first-seen":
+		// Tiles are already in the order they were found
+	case "scanline":
+		sort.SliceStable(frequencyTiles, func(a, b int) bool {
+			return firstSeenLess(frequencyTiles[a], frequencyTiles[b], false)
+		})
+	case "column-major":
+		sort.SliceStable(frequencyTiles, func(a, b int) bool {
+			return firstSeenLess(frequencyTiles[a], frequencyTiles[b], true)
+		})
+	case "luminance":
+		luminances := map[string]float64{}
+		for _, frequencyTile := range frequencyTiles {
+			luminances[frequencyTile.Hash] = i.Luminance(i.MeanColor(frequencyTile.Image))
+		}
+		sort.SliceStable(frequencyTiles, func(a, b int) bool {
+			la, lb := luminances[frequencyTiles[a].Hash], luminances[frequencyTiles[b].Hash]
+			if la != lb {
+				return la < lb
+			}
+			return firstSeenLess(frequencyTiles[a], frequencyTiles[b], false)
+		})
+	case "hue":
+		hues := map[string]float64{}
+		luminances := map[string]float64{}
+		for _, frequencyTile := range frequencyTiles {
+			mean := i.MeanColor(frequencyTile.Image)
+			hues[frequencyTile.Hash] = i.Hue(mean)
+			luminances[frequencyTile.Hash] = i.Luminance(mean)
+		}
+		sort.SliceStable(frequencyTiles, func(a, b int) bool {
+			ha, hb := hues[frequencyTiles[a].Hash], hues[frequencyTiles[b].Hash]
+			if ha != hb {
+				return ha < hb
+			}
+			la, lb := luminances[frequencyTiles[a].Hash], luminances[frequencyTiles[b].Hash]
+			if la != lb {
+				return la < lb
+			}
+			return firstSeenLess(frequencyTiles[a], frequencyTiles[b], false)
+		})
+	case "similarity":
+		orderTiles(frequencyTiles, "frequency")
+		orderBySimilarity(frequencyTiles)
+	default:
+		sort.SliceStable(frequencyTiles, func(a, b int) bool {
+			if frequencyTiles[a].Count != frequencyTiles[b].Count {
+				return frequencyTiles[a].Count > frequencyTiles[b].Count
+			}
+			return firstSeenLess(frequencyTiles[a], frequencyTiles[b], false)
+		})
+	}
+}
+
+// orderBySimilarity chains tiles so that each is followed by the remaining
+// tile that differs from it the least, starting from the first tile
+func orderBySimilarity(frequencyTiles []i.FrequencyTile) {
+	for j := 1; j < len(frequencyTiles); j++ {
+		previous := frequencyTiles[j-1].Image
+		closest := j
+		closestDistance := i.SquaredDistance(previous, frequencyTiles[j].Image)
+		for k := j + 1; k < len(frequencyTiles); k++ {
+			if distance := i.SquaredDistance(previous, frequencyTiles[k].Image); distance < closestDistance {
+				closest = k
+				closestDistance = distance
+			}
+		}
+		// Shift rather than swap so that ties keep their order
+		chosen := frequencyTiles[closest]
+		copy(frequencyTiles[j+1:closest+1], frequencyTiles[j:closest])
+		frequencyTiles[j] = chosen
+	}
+}
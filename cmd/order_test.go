@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestOrderTiles(t *testing.T) {
+
+	colors := map[string]color.NRGBA{
+		"R": {R: 255, A: 255},
+		"G": {G: 255, A: 255},
+		"B": {B: 255, A: 255},
+		"K": {A: 255},
+		"W": {R: 255, G: 255, B: 255, A: 255},
+	}
+	// B is used three times, R twice and the rest once
+	grid := []string{
+		"RKBR",
+		"BGWB",
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, len(grid[0])*testTileSize, len(grid)*testTileSize))
+	for row, line := range grid {
+		for column, name := range line {
+			rect := image.Rect(column*testTileSize, row*testTileSize, (column+1)*testTileSize, (row+1)*testTileSize)
+			draw.Draw(img, rect, image.NewUniform(colors[string(name)]), image.Point{}, draw.Src)
+		}
+	}
+
+	expected := map[string]string{
+		// Ties in count are broken by where the tile was first seen
+		"frequency":    "BRKGW",
+		"first-seen":   "RKBGW",
+		"scanline":     "RKBGW",
+		"column-major": "RKGBW",
+		"luminance":    "KBRGW",
+		// Red, black and white all have a hue of 0, so are ordered dark to light
+		"hue": "KRWGB",
+		// From the most used tile, black is closest to blue, then red and green
+		// tie with black and the earlier one is taken
+		"similarity": "BKRGW",
+	}
+	for _, order := range tileOrders {
+		t.Run(order, func(t *testing.T) {
+			parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize, Order: order}
+			_, frequencyTiles, _, _ := parse([]*image.NRGBA{img}, nil, parseConfig, nil, false)
+
+			names := []string{}
+			for _, frequencyTile := range frequencyTiles {
+				mean := i.MeanColor(frequencyTile.Image)
+				for name, c := range colors {
+					if c == mean {
+						names = append(names, name)
+					}
+				}
+			}
+			if actual := strings.Join(names, ""); actual != expected[order] {
+				t.Errorf("expected %s, got %s", expected[order], actual)
+			}
+		})
+	}
+}
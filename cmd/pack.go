@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/disintegration/imaging"
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var packCmd *cobra.Command
+
+var packExtrusion int
+var packDedupe bool
+
+// packFilenames expands directories to the images directly inside them,
+// sorted by name. Other arguments are files or glob patterns.
+func packFilenames(args []string) ([]string, error) {
+	filenames := []string{}
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil || !info.IsDir() {
+			expanded, err := expandFilenames([]string{arg})
+			if err != nil {
+				return nil, err
+			}
+			filenames = append(filenames, expanded...)
+			continue
+		}
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		dirFilenames := []string{}
+		for _, entry := range entries {
+			if _, err := imaging.FormatFromFilename(entry.Name()); err == nil && !entry.IsDir() {
+				dirFilenames = append(dirFilenames, filepath.Join(arg, entry.Name()))
+			}
+		}
+		sort.Strings(dirFilenames)
+		filenames = append(filenames, dirFilenames...)
+	}
+	return filenames, nil
+}
+
+// packTiles reads the tiles of each image, which is either a single tile or a
+// sheet of tiles read with the tileset's margin and spacing. It returns the
+// tiles to pack and where each source tile went.
+func packTiles(imgs []*image.NRGBA, filenames []string, tileset i.TilesetConfig, dedupe bool) ([]*image.NRGBA, []i.PackManifestTile, error) {
+	tiles := []*image.NRGBA{}
+	manifestTiles := []i.PackManifestTile{}
+	indices := map[string]int{}
+	for j, img := range imgs {
+		sheet := tileset
+		sheet.TileImages = nil
+		if img.Bounds().Dx() == tileset.TileWidth && img.Bounds().Dy() == tileset.TileHeight {
+			sheet.Columns = 1
+			sheet.TileImages = []*image.NRGBA{img}
+		} else if err := sheet.ReadImage(img); err != nil {
+			return nil, nil, fmt.Errorf("%s is %dx%d, which isn't a %dx%d tile or a sheet of them: %s",
+				filenames[j], img.Bounds().Dx(), img.Bounds().Dy(), tileset.TileWidth, tileset.TileHeight, err.Error())
+		}
+
+		for k, tileImage := range sheet.TileImages {
+			hash := hashNrgba(tileImage)
+			index, ok := indices[hash]
+			if !ok || !dedupe {
+				index = len(tiles)
+				indices[hash] = index
+				tiles = append(tiles, tileImage)
+			}
+			manifestTiles = append(manifestTiles, i.PackManifestTile{
+				Filename: filenames[j],
+				Column:   k % sheet.Columns,
+				Row:      k / sheet.Columns,
+				Index:    index,
+			})
+		}
+	}
+	return tiles, manifestTiles, nil
+}
+
+func init() {
+
+	packCmd = &cobra.Command{
+		Use:   "pack <directory|filename>...",
+		Short: "Pack tile images into a tileset.",
+		Long:  "The pack command builds a tileset from separate tile images. Directories are expanded to the images in them, in name order. Each image must be a single tile of the tile size, or a sheet of tiles that is read with the size, margin and spacing flags. Tiles are packed in order, and identical tiles can be packed once with dedupe. The tileset is written with the out-margin and out-spacing, and tiles can be extruded, in which case the extrusion is part of the out-margin and out-spacing. A json manifest next to the tileset maps each source tile to its tileset index and position.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				fmt.Fprintln(os.Stderr, "At least one arg required: <directory|filename>...")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool pack --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if err := i.ValidatePixelValue(outMargin); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid out-margin: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidatePixelValue(outSpacing); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid out-spacing: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidatePixelValue(packExtrusion); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid extrusion: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if outMargin < packExtrusion || outSpacing < 2*packExtrusion {
+				fmt.Fprintf(os.Stderr, "Invalid extrusion: out-margin must be at least %d and out-spacing at least %d to hold it\n", packExtrusion, 2*packExtrusion)
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(extrudeMode, extrudeModes); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid mode: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(metadataFormat, metadataFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid metadata: %s\n", err.Error())
+				os.Exit(1)
+			}
+			validateSheetLayout()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filenames, err := packFilenames(args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error finding images: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if len(filenames) == 0 {
+				fmt.Fprintln(os.Stderr, "Error: no images to pack")
+				os.Exit(1)
+			}
+
+			imgs := make([]*image.NRGBA, len(filenames))
+			for j, filename := range filenames {
+				imgs[j] = i.Open(filename, Verbose)
+			}
+
+			tiles, manifestTiles, err := packTiles(imgs, filenames, tc, packDedupe)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading tiles: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if Verbose {
+				fmt.Printf("Packing %d tiles from %d images, %d unique\n", len(manifestTiles), len(imgs), len(tiles))
+			}
+
+			outTc := i.NewTilesetConfig(
+				tc.TileWidth+(2*packExtrusion), tc.TileHeight+(2*packExtrusion),
+				outMargin-packExtrusion, outSpacing-(2*packExtrusion), BgColor)
+			for _, tile := range tiles {
+				if packExtrusion > 0 {
+					tile = extrudeTile(tile, packExtrusion, extrudeMode, BgColor)
+				}
+				outTc.TileImages = append(outTc.TileImages, tile)
+			}
+			reflow(&outTc, Verbose)
+
+			metaTc := outTc
+			metaTc.TileWidth = tc.TileWidth
+			metaTc.TileHeight = tc.TileHeight
+			metaTc.Margin = outMargin
+			metaTc.Spacing = outSpacing
+
+			for j := range manifestTiles {
+				index := manifestTiles[j].Index
+				pos := metaTc.TilePosition(index/metaTc.Columns, index%metaTc.Columns)
+				manifestTiles[j].X = pos.X
+				manifestTiles[j].Y = pos.Y
+			}
+
+			tilesetImage := outTc.ToImage()
+			i.Save(tilesetImage, Output, Verbose)
+
+			if metadataFormat != "none" {
+				writeTilesetMetadata(metadataFormat, metaTc, Verbose)
+			}
+
+			manifest := i.PackManifest{
+				Image:      filepath.Base(Output),
+				TileWidth:  metaTc.TileWidth,
+				TileHeight: metaTc.TileHeight,
+				Margin:     metaTc.Margin,
+				Spacing:    metaTc.Spacing,
+				Columns:    metaTc.Columns,
+				TileCount:  len(metaTc.TileImages),
+				Tiles:      manifestTiles,
+			}
+			manifestFilename := tilesetMetadataFilename(".json")
+			if Verbose {
+				fmt.Printf("Saving manifest to %s\n", manifestFilename)
+			}
+			exitOnSaveError(manifest.Write(manifestFilename))
+		},
+	}
+	packCmd.Flags().IntVar(&outMargin, "out-margin", 0, "output tileset margin in pixels (default 0)")
+	packCmd.Flags().IntVar(&outSpacing, "out-spacing", 0, "output tile spacing in pixels (default 0)")
+	packCmd.Flags().IntVar(&packExtrusion, "extrusion", 0, "extrusion thickness in pixels, taken from the out-margin and out-spacing (default 0)")
+	packCmd.Flags().StringVar(&extrudeMode, "mode", "clamp", fmt.Sprintf("how to fill the extrusion. %s", validExtrudeModesMessage))
+	packCmd.Flags().BoolVar(&packDedupe, "dedupe", false, "pack identical tiles once (default false)")
+	packCmd.Flags().StringVar(&metadataFormat, "metadata", "none", fmt.Sprintf("tileset metadata format to write alongside the tileset. %s", validMetadataFormatsMessage))
+	addSheetLayoutFlags(packCmd)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestPack(t *testing.T) {
+
+	sheet := i.Open("../fixtures/test_02.png", false)
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	tileset.ReadImage(sheet)
+
+	// The sheet, then each of its tiles as a file of its own
+	dir := t.TempDir()
+	imgs := []*image.NRGBA{sheet}
+	filenames := []string{"sheet.png"}
+	for j, tileImage := range tileset.TileImages {
+		filename := filepath.Join(dir, string(rune('a'+j))+".png")
+		i.Save(tileImage, filename, false)
+		imgs = append(imgs, tileImage)
+		filenames = append(filenames, filename)
+	}
+
+	found, err := packFilenames([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(tileset.TileImages) || found[0] != filepath.Join(dir, "a.png") {
+		t.Errorf("expected the %d tile files in name order, got %v", len(tileset.TileImages), found)
+	}
+
+	tiles, manifestTiles, err := packTiles(imgs, filenames, i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiles) != 2*len(tileset.TileImages) || len(manifestTiles) != len(tiles) {
+		t.Errorf("expected %d tiles without dedupe, got %d", 2*len(tileset.TileImages), len(tiles))
+	}
+
+	tiles, manifestTiles, err = packTiles(imgs, filenames, i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unique := map[string]bool{}
+	for _, tileImage := range tileset.TileImages {
+		unique[hashNrgba(tileImage)] = true
+	}
+	if len(tiles) != len(unique) {
+		t.Errorf("expected %d unique tiles, got %d", len(unique), len(tiles))
+	}
+	for j, manifestTile := range manifestTiles[len(tileset.TileImages):] {
+		sheetTile := manifestTiles[j]
+		if manifestTile.Index != sheetTile.Index {
+			t.Errorf("%s: expected the index of sheet tile %dx%d: %d, got %d",
+				manifestTile.Filename, sheetTile.Column, sheetTile.Row, sheetTile.Index, manifestTile.Index)
+		}
+	}
+
+	wrongSize := image.NewNRGBA(image.Rect(0, 0, testTileSize+1, testTileSize))
+	if _, _, err := packTiles([]*image.NRGBA{wrongSize}, []string{"wrong.png"}, tileset, false); err == nil {
+		t.Errorf("expected an error for an image that isn't a tile or a sheet of tiles")
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"image"
 	"os"
-	"sort"
+	"path/filepath"
 	"strings"
 
 	"github.com/disintegration/imaging"
@@ -23,6 +23,21 @@ var xOffset int
 var yOffset int
 
 var transform bool
+var mapFormat string
+var baseTileset string
+var tileOrder string
+var scanOrder string
+var tolerance float64
+var metric string
+
+var metrics = []string{"max", "mean"}
+
+var skipEmpty bool
+var skipColorHex string
+
+var scanOrders = []string{"row", "column", "serpentine", "hilbert"}
+
+const validScanOrdersMessage = "Valid scan orders are: \"row\" (left to right, then top to bottom), \"column\" (top to bottom, then left to right), \"serpentine\" (rows alternating direction) and \"hilbert\" (along a Hilbert curve)."
 
 func transformCrop(transformType string, crop *image.NRGBA) *image.NRGBA {
 	transformTypes := strings.Split(transformType, "-")
@@ -74,59 +89,144 @@ func hashNrgba(nrgba *image.NRGBA) string {
 	return hash
 }
 
-func computeFreq(img *image.NRGBA, crops []*image.NRGBA, transformations []string) []i.FrequencyTile {
+// computeFreq finds the unique tiles among the crops of one or more source
+// images, returning the occurrences of each source in the order of its crops.
+// Base tiles keep their indices at the start of the tileset and new tiles are
+// added after them in the configured order. With a tolerance, crops without
+// an exact match are merged into the closest tile within the tolerance.
+// Skipped crops have an index of -1.
+func computeFreq(baseTiles []*image.NRGBA, crops [][]*image.NRGBA, transformations []string, parseConfig i.ParseConfig) ([]i.FrequencyTile, [][]i.TileOccurrence) {
 	frequencyTiles := []i.FrequencyTile{}
+	occurrences := make([][]i.TileOccurrence, len(crops))
 	lookup := map[string]int{}
 	tileIndex := 0
-	fmt.Println()
-	for _, crop := range crops {
-		baseOrientationHash := hashNrgba(crop)
-
-		foundTransformation := false
-		for _, transformation := range transformations {
-			transformedCrop := transformCrop(transformation, crop)
-			hash := hashNrgba(transformedCrop)
-			// fmt.Printf("%v: %s: %v\n", pixels, hash, transformedCrop.Bounds().Min)
-
-			// If the hash is the same as the base orientation has, then don't bother
-			// searching with it
-			if hash == baseOrientationHash {
+	for _, baseTile := range baseTiles {
+		hash := hashNrgba(baseTile)
+		frequencyTiles = append(frequencyTiles, i.FrequencyTile{Hash: hash, Image: baseTile})
+		if _, ok := lookup[hash]; !ok {
+			lookup[hash] = tileIndex
+		}
+		tileIndex++
+	}
+
+	countOccurrence := func(index, source int, location image.Point) {
+		if frequencyTiles[index].Count == 0 {
+			frequencyTiles[index].FirstSource = source
+			frequencyTiles[index].FirstLocation = location
+		}
+		frequencyTiles[index].Count++
+	}
+
+	for source, sourceCrops := range crops {
+		occurrences[source] = make([]i.TileOccurrence, len(sourceCrops))
+		for j, crop := range sourceCrops {
+			occurrence := &occurrences[source][j]
+			occurrence.Source = source
+			occurrence.Location = crop.Bounds().Min
+			occurrence.Transformation = i.IdentityTransformation
+			if parseConfig.IsSkipped(crop) {
+				occurrence.Index = -1
 				continue
 			}
+			baseOrientationHash := hashNrgba(crop)
+
+			foundTransformation := false
+			for _, transformation := range transformations {
+				transformedCrop := transformCrop(transformation, crop)
+				hash := hashNrgba(transformedCrop)
+
+				// If the hash is the same as the base orientation has, then don't bother
+				// searching with it
+				if hash == baseOrientationHash {
+					continue
+				}
+
+				if index, ok := lookup[hash]; ok {
+					countOccurrence(index, source, occurrence.Location)
+					occurrence.Index = index
+					occurrence.Transformation = transformation
+					occurrence.Flip = i.FlipForTransformation(transformation)
+					foundTransformation = true
+					break
+				}
+			}
 
-			if index, ok := lookup[hash]; ok {
-				frequencyTiles[index].Count++
-				frequencyTiles[index].Transformations = true
-				foundTransformation = true
-				break
+			// If we've already found this tile with a transformation, skip the base orientation
+			if foundTransformation {
+				continue
+			}
+			if index, ok := lookup[baseOrientationHash]; ok {
+				countOccurrence(index, source, occurrence.Location)
+				occurrence.Index = index
+			} else if index, transformation, distance, ok := findSimilar(crop, frequencyTiles, transformations, parseConfig); ok {
+				countOccurrence(index, source, occurrence.Location)
+				occurrence.Index = index
+				occurrence.Transformation = transformation
+				occurrence.Flip = i.FlipForTransformation(transformation)
+				occurrence.Error = distance
+				if distance > frequencyTiles[index].MaxError {
+					frequencyTiles[index].MaxError = distance
+				}
+			} else {
+				frequencyTile := i.FrequencyTile{
+					Hash:          baseOrientationHash,
+					Image:         crop,
+					Count:         1,
+					FirstSource:   source,
+					FirstLocation: crop.Bounds().Min,
+				}
+				frequencyTiles = append(frequencyTiles, frequencyTile)
+				lookup[baseOrientationHash] = tileIndex
+				occurrence.Index = tileIndex
+				tileIndex++
 			}
 		}
+	}
 
-		// If we've already found this tile with a transformation, skip the base orientation
-		if foundTransformation {
-			continue
-		}
-		if index, ok := lookup[baseOrientationHash]; ok {
-			frequencyTiles[index].Count++
-		} else {
-			frequencyTile := i.FrequencyTile{
-				Hash:            baseOrientationHash,
-				Image:           crop,
-				Count:           1,
-				FirstLocation:   crop.Bounds().Min,
-				Transformations: false,
+	orderTiles(frequencyTiles[len(baseTiles):], parseConfig.Order)
+
+	// Point the occurrences at the sorted tileset indices
+	remap := make([]int, len(frequencyTiles))
+	for index := range baseTiles {
+		remap[index] = index
+	}
+	for index := len(baseTiles); index < len(frequencyTiles); index++ {
+		remap[lookup[frequencyTiles[index].Hash]] = index
+	}
+	for _, sourceOccurrences := range occurrences {
+		for j := range sourceOccurrences {
+			if sourceOccurrences[j].Index < 0 {
+				continue
 			}
-			frequencyTiles = append(frequencyTiles, frequencyTile)
-			lookup[baseOrientationHash] = tileIndex
-			tileIndex++
+			index := remap[sourceOccurrences[j].Index]
+			sourceOccurrences[j].Index = index
+			frequencyTiles[index].Occurrences = append(frequencyTiles[index].Occurrences, sourceOccurrences[j])
 		}
 	}
 
-	sort.Slice(frequencyTiles, func(i, j int) bool {
-		return frequencyTiles[i].Count > frequencyTiles[j].Count
-	})
+	return frequencyTiles, occurrences
+}
 
-	return frequencyTiles
+// findSimilar looks for the tile closest to the crop, in any allowed
+// orientation, that is within the tolerance. The first closest is chosen, so
+// each tile found this way stands for a cluster of similar crops.
+func findSimilar(crop *image.NRGBA, frequencyTiles []i.FrequencyTile, transformations []string, parseConfig i.ParseConfig) (index int, transformation string, distance float64, ok bool) {
+	if parseConfig.Tolerance <= 0 {
+		return
+	}
+	distance = parseConfig.Tolerance
+	for _, candidate := range append([]string{i.IdentityTransformation}, transformations...) {
+		transformedCrop := transformCrop(candidate, crop)
+		for j, frequencyTile := range frequencyTiles {
+			if frequencyTile.Image.Bounds().Size() != transformedCrop.Bounds().Size() {
+				continue
+			}
+			if d := parseConfig.Distance(transformedCrop, frequencyTile.Image); d <= distance && (!ok || d < distance) {
+				index, transformation, distance, ok = j, candidate, d, true
+			}
+		}
+	}
+	return
 }
 
 func CreateTransformations() []string {
@@ -141,46 +241,142 @@ func CreateTransformations() []string {
 	return transformations
 }
 
-func parse(img *image.NRGBA, parseConfig i.ParseConfig, transformations []string, verbose bool) ([]*image.NRGBA, []i.FrequencyTile, error) {
+func parse(imgs []*image.NRGBA, baseTiles []*image.NRGBA, parseConfig i.ParseConfig, transformations []string, verbose bool) ([][]*image.NRGBA, []i.FrequencyTile, []i.TileMap, error) {
+	tiles := make([][]*image.NRGBA, len(imgs))
+	for source, img := range imgs {
+		if verbose {
+			bounds := img.Bounds()
+			imgSize := fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy())
+			tileSize := fmt.Sprintf("%dx%d", parseConfig.TileWidth, parseConfig.TileHeight)
+			leftOverSize := fmt.Sprintf("%dx%d", bounds.Dx()%parseConfig.TileWidth, bounds.Dy()%parseConfig.TileHeight)
+			offsetSize := fmt.Sprintf("%dx%d", parseConfig.XOffset, parseConfig.YOffset)
+			fmt.Printf("Parsing %s image (offset by %s) for %s tiles with %s remainder\n", imgSize, offsetSize, tileSize, leftOverSize)
+		}
+		tiles[source] = parseConfig.CropTiles(img)
+	}
+
+	frequencyTiles, occurrences := computeFreq(baseTiles, tiles, transformations, parseConfig)
+
+	tileMaps := make([]i.TileMap, len(imgs))
+	for source, img := range imgs {
+		tileMaps[source] = parseConfig.NewTileMap(img, occurrences[source])
+	}
+
+	return tiles, frequencyTiles, tileMaps, nil
+}
+
+// readBaseTileset reads the tiles of an existing tileset with the size, margin
+// and spacing flags. The output tileset keeps its columns. Every tile is kept,
+// even an empty one, so that indices don't move. Only when tsx or tsj metadata
+// is saved next to the tileset are the cells past its tile count dropped, as
+// padding from an incomplete last row.
+func readBaseTileset(filename string, verbose bool) []*image.NRGBA {
+	img := i.Open(filename, verbose)
+	baseTc := tc
+	if err := baseTc.ReadImage(img); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading base tileset: %s\n", err.Error())
+		os.Exit(1)
+	}
+	tc.Columns = baseTc.Columns
+
+	baseTiles := baseTc.TileImages
+	for _, extension := range []string{".tsx", ".tsj"} {
+		metadataFilename := strings.TrimSuffix(filename, filepath.Ext(filename)) + extension
+		if _, err := os.Stat(metadataFilename); err != nil {
+			continue
+		}
+		columns, tileCount, err := i.ReadTilesetMetadata(metadataFilename)
+		if err == nil && columns != baseTc.Columns {
+			err = fmt.Errorf("it has %d columns, but the tileset image has %d", columns, baseTc.Columns)
+		}
+		if err == nil && tileCount > len(baseTiles) {
+			err = fmt.Errorf("it has %d tiles, but the tileset image has %d", tileCount, len(baseTiles))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading base tileset metadata %s: %s\n", metadataFilename, err.Error())
+			os.Exit(1)
+		}
+		if verbose {
+			fmt.Printf("Read tile count of %d from %s\n", tileCount, metadataFilename)
+		}
+		baseTiles = baseTiles[:tileCount]
+		break
+	}
 	if verbose {
-		bounds := img.Bounds()
-		imgSize := fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy())
-		tileSize := fmt.Sprintf("%dx%d", parseConfig.TileWidth, parseConfig.TileHeight)
-		leftOverSize := fmt.Sprintf("%dx%d", bounds.Dx()%parseConfig.TileWidth, bounds.Dy()%parseConfig.TileHeight)
-		offsetSize := fmt.Sprintf("%dx%d", xOffset, yOffset)
-		fmt.Printf("Parsing %s image (offset by %s) for %s tiles with %s remainder\n", imgSize, offsetSize, tileSize, leftOverSize)
+		fmt.Printf("Read %d base tiles, dropped %d padding tiles\n", len(baseTiles), len(baseTc.TileImages)-len(baseTiles))
 	}
-	tiles := parseConfig.CropTiles(img)
+	return baseTiles
+}
 
-	frequencyTiles := computeFreq(img, tiles, transformations)
+// Filenames can be given as glob patterns, for shells that don't expand them
+func expandFilenames(patterns []string) ([]string, error) {
+	filenames := []string{}
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			matches = []string{pattern}
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				filenames = append(filenames, match)
+			}
+		}
+	}
+	return filenames, nil
+}
 
-	return tiles, frequencyTiles, nil
+// Summarize how often each transformation was needed to match a tile
+func describeTransformations(frequencyTile i.FrequencyTile) string {
+	counts := map[string]int{}
+	for _, occurrence := range frequencyTile.Occurrences {
+		counts[occurrence.Transformation]++
+	}
+	descriptions := []string{}
+	for _, transformation := range frequencyTile.Transformations() {
+		descriptions = append(descriptions, fmt.Sprintf("%s x%d", transformation, counts[transformation]))
+	}
+	if len(descriptions) == 0 {
+		return "none"
+	}
+	return strings.Join(descriptions, ", ")
 }
 
-func outputTable(frequencyTiles []i.FrequencyTile) {
+func outputTable(frequencyTiles []i.FrequencyTile, filenames []string) {
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
+	header := table.Row{"Tileset Index", "Count", "First Location"}
 	if transform {
-		t.AppendHeader(table.Row{"Tileset Index", "Count", "First Location", "Transformation Required"})
-	} else {
-		t.AppendHeader(table.Row{"Tileset Index", "Count", "First Location"})
+		header = append(header, "Transformations")
+	}
+	if tolerance > 0 {
+		header = append(header, "Max Error")
 	}
+	t.AppendHeader(header)
 
 	for i, frequencyTile := range frequencyTiles {
+		firstLocation := fmt.Sprintf("%v", frequencyTile.FirstLocation)
+		if frequencyTile.Count == 0 {
+			firstLocation = "-"
+		} else if len(filenames) > 1 {
+			firstLocation = fmt.Sprintf("%s %v", filenames[frequencyTile.FirstSource], frequencyTile.FirstLocation)
+		}
+		row := table.Row{
+			fmt.Sprintf("%d", i),
+			fmt.Sprintf("%d", frequencyTile.Count),
+			firstLocation,
+		}
 		if transform {
-			t.AppendRow(table.Row{
-				fmt.Sprintf("%d", i),
-				fmt.Sprintf("%d", frequencyTile.Count),
-				fmt.Sprintf("%v", frequencyTile.FirstLocation),
-				fmt.Sprintf("%t", frequencyTile.Transformations),
-			})
-		} else {
-			t.AppendRow(table.Row{
-				fmt.Sprintf("%d", i),
-				fmt.Sprintf("%d", frequencyTile.Count),
-				fmt.Sprintf("%v", frequencyTile.FirstLocation),
-			})
+			row = append(row, describeTransformations(frequencyTile))
+		}
+		if tolerance > 0 {
+			row = append(row, fmt.Sprintf("%.2f", frequencyTile.MaxError))
 		}
+		t.AppendRow(row)
 	}
 	t.Render()
 }
@@ -188,53 +384,157 @@ func outputTable(frequencyTiles []i.FrequencyTile) {
 func init() {
 
 	parseCmd = &cobra.Command{
-		Use:   "parse <filename>",
-		Short: "Parse a tileset from an image.",
-		Long:  "The parse command processes an image and identifies the set of unique tiles that compose it, which are then output as a tileset. Verbose output will list a frequency count for all tiles, their first location in the image and whether it was necessary to transform them by flipping or rotation.",
+		Use:   "parse <filename>...",
+		Short: "Parse a tileset from one or more images.",
+		Long:  "The parse command processes an image and identifies the set of unique tiles that compose it, which are then output as a tileset. Verbose output will list a frequency count for all tiles, their first location in the image and whether it was necessary to transform them by flipping or rotation. With a size of auto, the tile size and offset are detected as by the detect command. When several images (or glob patterns) are given, their unique tiles are combined into one shared tileset. With a map format, the grid of tileset indices that recreates each image is also written, named after the image and saved next to the tileset.",
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 1 {
-				fmt.Fprintln(os.Stderr, "One arg required: <filename>")
+			if len(args) < 1 {
+				fmt.Fprintln(os.Stderr, "At least one arg required: <filename>...")
 				fmt.Fprintln(os.Stderr, "Use \"tiletool parse --help\" for more information.")
 				os.Exit(1)
 			}
 			return nil
 		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if err := i.ValidateChoice(mapFormat, mapFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid format: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(scanOrder, scanOrders); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid scan: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if tolerance < 0 || tolerance > 255 {
+				fmt.Fprintln(os.Stderr, "Invalid tolerance: value must be in range [0, 255]")
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(metric, metrics); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid metric: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if skipColorHex != "" {
+				if _, err := i.ColorFromHex(skipColorHex); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid skip-color: %s\n", err.Error())
+					os.Exit(1)
+				}
+			}
+			if err := i.ValidateChoice(tileOrder, tileOrders); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid order: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if mapFormat == "ldtk" && tc.TileWidth != tc.TileHeight {
+				fmt.Fprintln(os.Stderr, "Invalid format: LDtk only supports square tiles")
+				os.Exit(1)
+			}
+			validateSheetLayout()
+		},
 		Run: func(cmd *cobra.Command, args []string) {
-			filename := args[0]
+			filenames, err := expandFilenames(args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid filename: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if mapFormat != "none" {
+				if err := validateMapFilenames(filenames); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid filename: %s\n", err.Error())
+					os.Exit(1)
+				}
+			}
+
+			imgs := make([]*image.NRGBA, len(filenames))
+			for j, filename := range filenames {
+				imgs[j] = i.Open(filename, Verbose)
+			}
+
+			if autoSize {
+				candidates := detectGrid(imgs, defaultDetectSizes)
+				if len(candidates) == 0 {
+					fmt.Fprintln(os.Stderr, "Error: couldn't detect a tile size, the image is smaller than every tile size")
+					os.Exit(1)
+				}
+				best := candidates[0].parseConfig
+				tc.TileWidth = best.TileWidth
+				tc.TileHeight = best.TileHeight
+				xOffset = best.XOffset
+				yOffset = best.YOffset
+				fmt.Printf("Detected tile size: %dx%d with offset: %dx%d\n", tc.TileWidth, tc.TileHeight, xOffset, yOffset)
+			}
 
 			parseConfig := i.ParseConfig{
 				TileWidth:  tc.TileWidth,
 				TileHeight: tc.TileHeight,
 				XOffset:    xOffset,
 				YOffset:    yOffset,
+				Order:      tileOrder,
+				ScanOrder:  scanOrder,
+				Tolerance:  tolerance,
+				Metric:     metric,
+				SkipEmpty:  skipEmpty,
+			}
+			if skipColorHex != "" {
+				parseConfig.SkipColor, _ = i.ColorFromHex(skipColorHex)
 			}
 			var transformations []string
 			if transform {
 				transformations = CreateTransformations()
+				// Rotating non-square tiles by 90 degrees would change their size
+				if mapFormat == "ldtk" || tc.TileWidth != tc.TileHeight {
+					transformations = flipOnlyTransformations(transformations)
+				}
 			}
 
-			img := i.Open(filename, Verbose)
+			var baseTiles []*image.NRGBA
+			if baseTileset != "" {
+				baseTiles = readBaseTileset(baseTileset, Verbose)
+			}
 
-			tiles, frequencyTiles, err := parse(img, parseConfig, transformations, Verbose)
+			tiles, frequencyTiles, tileMaps, err := parse(imgs, baseTiles, parseConfig, transformations, Verbose)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error opening file: %s\n", err.Error())
 				os.Exit(1)
 			}
 			if Verbose {
-				fmt.Printf("Parsed %d total tiles, %d unique\n", len(tiles), len(frequencyTiles))
-				outputTable(frequencyTiles)
+				total := 0
+				for _, sourceTiles := range tiles {
+					total += len(sourceTiles)
+				}
+				skipped := 0
+				for _, tileMap := range tileMaps {
+					for _, cell := range tileMap.Cells {
+						if cell.Index < 0 {
+							skipped++
+						}
+					}
+				}
+				fmt.Printf("Parsed %d total tiles from %d images, %d unique, %d skipped\n", total, len(imgs), len(frequencyTiles), skipped)
+				if baseTileset != "" {
+					fmt.Printf("Kept %d base tileset tiles, added %d new tiles\n", len(baseTiles), len(frequencyTiles)-len(baseTiles))
+				}
+				outputTable(frequencyTiles, filenames)
 			}
 
 			for _, frequencyTile := range frequencyTiles {
 				tc.TileImages = append(tc.TileImages, frequencyTile.Image)
 			}
 
+			reflow(&tc, Verbose)
 			tilesetImage := tc.ToImage()
 			i.Save(tilesetImage, Output, Verbose)
+
+			writeMaps(filenames, tileMaps, Verbose)
 		},
 	}
 	parseCmd.Flags().IntVarP(&xOffset, "x-offset", "x", 0, "start at this x coordinate (default 0)")
 	parseCmd.Flags().IntVarP(&yOffset, "y-offset", "y", 0, "start at this y coordinate (default 0)")
-	parseCmd.Flags().BoolVarP(&transform, "transform", "t", false, "allow tiles to be flipped and rotated (default false)")
+	parseCmd.Flags().BoolVarP(&transform, "transform", "t", false, "allow tiles to be flipped and rotated. Non-square tiles are only flipped and rotated by 180 degrees (default false)")
+	parseCmd.Flags().Float64Var(&tolerance, "tolerance", 0, "merge tiles that differ by at most this much per channel, in [0, 255] (default 0, exact matches only)")
+	parseCmd.Flags().StringVar(&metric, "metric", "max", "how tolerance measures tile difference: \"max\" (largest channel difference of any pixel) or \"mean\" (mean channel difference)")
+	parseCmd.Flags().BoolVar(&skipEmpty, "skip-empty", false, "leave fully transparent tiles out of the tileset and empty in maps (default false)")
+	parseCmd.Flags().StringVar(&skipColorHex, "skip-color", "", "leave tiles entirely of this color out of the tileset and empty in maps, in 8 digit hex format (RGBA)")
+	parseCmd.Flags().StringVar(&scanOrder, "scan", "row", fmt.Sprintf("order to scan the image's tiles in, which decides where a tile is first seen. %s", validScanOrdersMessage))
+	parseCmd.Flags().StringVar(&tileOrder, "order", "frequency", fmt.Sprintf("order of new tiles in the tileset. %s", validOrdersMessage))
+	parseCmd.Flags().StringVar(&baseTileset, "base-tileset", "", "existing tileset whose tiles keep their indices. New tiles are added after them. A tsx or tsj next to it gives its tile count")
+	parseCmd.Flags().StringVarP(&mapFormat, "format", "f", "none", fmt.Sprintf("map format to write alongside the tileset. %s", validMapFormatsMessage))
+	addSheetLayoutFlags(parseCmd)
 
 }
@@ -2,11 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
 	"testing"
 
 	i "github.com/davidwarshaw/tiletool/cmd/internal"
 )
 
+const testTileSize = 16
+
 type parseTest struct {
 	filename       string
 	transform      bool
@@ -33,8 +39,8 @@ func TestParseBase(t *testing.T) {
 		name := fmt.Sprintf("%s-%t", tc.filename, tc.transform)
 		t.Run(name, func(t *testing.T) {
 			parseConfig := i.ParseConfig{
-				TileWidth:  tileSize,
-				TileHeight: tileSize,
+				TileWidth:  testTileSize,
+				TileHeight: testTileSize,
 				XOffset:    xOffset,
 				YOffset:    yOffset,
 			}
@@ -44,10 +50,11 @@ func TestParseBase(t *testing.T) {
 			}
 
 			img := i.Open(tc.filename, verbose)
-			tiles, frequencyTiles, _ := parse(img, parseConfig, transformations, verbose)
+			sourceTiles, frequencyTiles, _, _ := parse([]*image.NRGBA{img}, nil, parseConfig, transformations, verbose)
+			tiles := sourceTiles[0]
 			t.Logf("Parsed %d total tiles, %d unique\n", len(tiles), len(frequencyTiles))
 			for i, frequencyTile := range frequencyTiles {
-				t.Logf("Index: %d \tHash: %s \tCount: %d \tFirst Location: %v \tTransformation %t\n", i, frequencyTile.Hash, frequencyTile.Count, frequencyTile.FirstLocation, frequencyTile.Transformations)
+				t.Logf("Index: %d \tHash: %s \tCount: %d \tFirst Location: %v \tTransformations %v\n", i, frequencyTile.Hash, frequencyTile.Count, frequencyTile.FirstLocation, frequencyTile.Transformations())
 			}
 			actualTotal := len(tiles)
 			actualUnique := len(frequencyTiles)
@@ -60,3 +67,304 @@ func TestParseBase(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMap(t *testing.T) {
+
+	tests := []parseTest{
+		{filename: "../fixtures/test_01.png", transform: false},
+		{filename: "../fixtures/test_02.png", transform: true},
+		{filename: "../fixtures/test_03.png", transform: true},
+	}
+
+	for _, tc := range tests {
+
+		transformations := []string{}
+		verbose := false
+
+		name := fmt.Sprintf("%s-%t", tc.filename, tc.transform)
+		t.Run(name, func(t *testing.T) {
+			parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize}
+
+			if tc.transform {
+				transformations = CreateTransformations()
+			}
+
+			img := i.Open(tc.filename, verbose)
+			sourceTiles, frequencyTiles, tileMaps, _ := parse([]*image.NRGBA{img}, nil, parseConfig, transformations, verbose)
+			tiles, tileMap := sourceTiles[0], tileMaps[0]
+			if len(tileMap.Cells) != len(tiles) {
+				t.Fatalf("expected %d cells, got %d", len(tiles), len(tileMap.Cells))
+			}
+
+			// Drawing each cell's tileset tile with its flip must reproduce the crop
+			crops := map[image.Point]*image.NRGBA{}
+			for _, crop := range tiles {
+				crops[crop.Bounds().Min] = crop
+				column, row := parseConfig.GridPosition(crop.Bounds().Min)
+				cell := tileMap.Cell(column, row)
+				drawn := cell.Flip.Apply(frequencyTiles[cell.Index].Image)
+				if hashNrgba(drawn) != hashNrgba(crop) {
+					t.Errorf("cell %d,%d: index %d with flip %+v does not match the crop", column, row, cell.Index, cell.Flip)
+				}
+			}
+
+			// Each recorded transformation must turn its crop into the tileset tile
+			for index, frequencyTile := range frequencyTiles {
+				for _, occurrence := range frequencyTile.Occurrences {
+					crop := crops[occurrence.Location]
+					if hashNrgba(transformCrop(occurrence.Transformation, crop)) != frequencyTile.Hash {
+						t.Errorf("tile %d at %v: %s does not match the tile", index, occurrence.Location, occurrence.Transformation)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseNonSquare(t *testing.T) {
+
+	filenames := []string{"../fixtures/test_01.png", "../fixtures/test_02.png", "../fixtures/test_03.png"}
+
+	for _, filename := range filenames {
+		t.Run(filename, func(t *testing.T) {
+			parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize / 2}
+			transformations := flipOnlyTransformations(CreateTransformations())
+
+			img := i.Open(filename, false)
+			sourceTiles, frequencyTiles, tileMaps, _ := parse([]*image.NRGBA{img}, nil, parseConfig, transformations, false)
+			tiles, tileMap := sourceTiles[0], tileMaps[0]
+			if tileMap.Rows != 2*tileMap.Columns {
+				t.Errorf("expected twice as many rows as columns, got %dx%d", tileMap.Columns, tileMap.Rows)
+			}
+			for _, crop := range tiles {
+				column, row := parseConfig.GridPosition(crop.Bounds().Min)
+				cell := tileMap.Cell(column, row)
+				drawn := cell.Flip.Apply(frequencyTiles[cell.Index].Image)
+				if hashNrgba(drawn) != hashNrgba(crop) {
+					t.Errorf("cell %d,%d: index %d with flip %+v does not match the crop", column, row, cell.Index, cell.Flip)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMultiple(t *testing.T) {
+
+	filenames := []string{"../fixtures/test_01.png", "../fixtures/test_02.png", "../fixtures/test_03.png", "mixed"}
+
+	parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize}
+	imgs := make([]*image.NRGBA, len(filenames))
+	for j, filename := range filenames[:3] {
+		imgs[j] = i.Open(filename, false)
+	}
+	// The last tile of the first image next to the first tile of the second
+	mixed := image.NewNRGBA(image.Rect(0, 0, 2*testTileSize, testTileSize))
+	draw.Draw(mixed, image.Rect(0, 0, testTileSize, testTileSize), imgs[0], image.Pt(testTileSize, testTileSize), draw.Src)
+	draw.Draw(mixed, image.Rect(testTileSize, 0, 2*testTileSize, testTileSize), imgs[1], image.Point{}, draw.Src)
+	imgs[3] = mixed
+	_, frequencyTiles, tileMaps, _ := parse(imgs, nil, parseConfig, CreateTransformations(), false)
+
+	// Tiles shared between images are only counted once: 3 from the first
+	// image, 9 from the second, 1 from the third and none from the mixed one
+	if len(frequencyTiles) != 3+9+1 {
+		t.Errorf("expected %d unique tiles, got %d", 3+9+1, len(frequencyTiles))
+	}
+	if cell, shared := tileMaps[3].Cell(0, 0), tileMaps[0].Cell(1, 1); cell != shared {
+		t.Errorf("expected the tile shared with %s to be %+v, got %+v", filenames[0], shared, cell)
+	}
+	if cell, shared := tileMaps[3].Cell(1, 0), tileMaps[1].Cell(0, 0); cell != shared {
+		t.Errorf("expected the tile shared with %s to be %+v, got %+v", filenames[1], shared, cell)
+	}
+
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	for _, frequencyTile := range frequencyTiles {
+		tileset.TileImages = append(tileset.TileImages, frequencyTile.Image)
+	}
+	for j, tileMap := range tileMaps {
+		rendered, err := tileMap.Render(&tileset, color.Transparent)
+		if err != nil {
+			t.Fatalf("%s: error rendering map: %s", filenames[j], err.Error())
+		}
+		if hashNrgba(rendered) != hashNrgba(imgs[j]) {
+			t.Errorf("%s: rendered image is different than the parsed image", filenames[j])
+		}
+	}
+}
+
+func TestParseBaseTileset(t *testing.T) {
+
+	parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize}
+	img01 := i.Open("../fixtures/test_01.png", false)
+	img02 := i.Open("../fixtures/test_02.png", false)
+
+	_, baseFrequencyTiles, baseTileMaps, _ := parse([]*image.NRGBA{img01}, nil, parseConfig, nil, false)
+	baseTiles := []*image.NRGBA{}
+	for _, frequencyTile := range baseFrequencyTiles {
+		baseTiles = append(baseTiles, frequencyTile.Image)
+	}
+
+	_, frequencyTiles, tileMaps, _ := parse([]*image.NRGBA{img02, img01}, baseTiles, parseConfig, nil, false)
+
+	for index, baseFrequencyTile := range baseFrequencyTiles {
+		if frequencyTiles[index].Hash != baseFrequencyTile.Hash {
+			t.Errorf("base tile %d moved", index)
+		}
+	}
+	for j, cell := range baseTileMaps[0].Cells {
+		if tileMaps[1].Cells[j] != cell {
+			t.Errorf("cell %d: expected %+v, got %+v", j, cell, tileMaps[1].Cells[j])
+		}
+	}
+}
+
+func TestReadBaseTileset(t *testing.T) {
+
+	// Red, green and an empty tile in 2 columns, leaving one cell of padding
+	base := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	base.Columns = 2
+	for _, c := range []color.NRGBA{{R: 255, A: 255}, {G: 255, A: 255}, {}} {
+		tile := image.NewNRGBA(image.Rect(0, 0, testTileSize, testTileSize))
+		draw.Draw(tile, tile.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		base.TileImages = append(base.TileImages, tile)
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "base.png")
+	i.Save(base.ToImage(), filename, false)
+
+	tc = i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	if baseTiles := readBaseTileset(filename, false); len(baseTiles) != 4 {
+		t.Errorf("without metadata, expected every cell to be kept: %d, got %d", 4, len(baseTiles))
+	}
+
+	if err := base.WriteTSX(filepath.Join(dir, "base.tsx"), filename); err != nil {
+		t.Fatal(err)
+	}
+	baseTiles := readBaseTileset(filename, false)
+	if len(baseTiles) != 3 {
+		t.Fatalf("with metadata, expected %d tiles, got %d", 3, len(baseTiles))
+	}
+	if !i.IsUniform(baseTiles[2], color.NRGBA{}) {
+		t.Errorf("expected the empty last tile to be kept")
+	}
+
+	// The empty tile keeps its index instead of being added again
+	img := image.NewNRGBA(image.Rect(0, 0, 2*testTileSize, testTileSize))
+	draw.Draw(img, image.Rect(0, 0, testTileSize, testTileSize), base.TileImages[1], image.Point{}, draw.Src)
+	parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize}
+	_, frequencyTiles, tileMaps, _ := parse([]*image.NRGBA{img}, baseTiles, parseConfig, nil, false)
+	if len(frequencyTiles) != 3 {
+		t.Errorf("expected %d tiles, got %d", 3, len(frequencyTiles))
+	}
+	if index := tileMaps[0].Cell(1, 0).Index; index != 2 {
+		t.Errorf("expected the empty cell to have index %d, got %d", 2, index)
+	}
+}
+
+func TestScanPositions(t *testing.T) {
+
+	// Each scan of a 4x4 grid as column, row pairs
+	expected := map[string][]int{
+		"row": {0, 0, 1, 0, 2, 0, 3, 0, 0, 1, 1, 1, 2, 1, 3, 1,
+			0, 2, 1, 2, 2, 2, 3, 2, 0, 3, 1, 3, 2, 3, 3, 3},
+		"column": {0, 0, 0, 1, 0, 2, 0, 3, 1, 0, 1, 1, 1, 2, 1, 3,
+			2, 0, 2, 1, 2, 2, 2, 3, 3, 0, 3, 1, 3, 2, 3, 3},
+		"serpentine": {0, 0, 1, 0, 2, 0, 3, 0, 3, 1, 2, 1, 1, 1, 0, 1,
+			0, 2, 1, 2, 2, 2, 3, 2, 3, 3, 2, 3, 1, 3, 0, 3},
+		// From the top left corner to the top right one, one step at a time
+		"hilbert": {0, 0, 1, 0, 1, 1, 0, 1, 0, 2, 0, 3, 1, 3, 1, 2,
+			2, 2, 2, 3, 3, 3, 3, 2, 3, 1, 2, 1, 2, 0, 3, 0},
+	}
+
+	for _, scan := range scanOrders {
+		t.Run(scan, func(t *testing.T) {
+			parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize, ScanOrder: scan}
+
+			positions := parseConfig.ScanPositions(4, 4)
+			if len(positions) != len(expected[scan])/2 {
+				t.Fatalf("expected %d positions, got %d", len(expected[scan])/2, len(positions))
+			}
+			for j, position := range positions {
+				if want := image.Pt(expected[scan][2*j], expected[scan][2*j+1]); position != want {
+					t.Errorf("position %d: expected %v, got %v", j, want, position)
+				}
+			}
+
+			// A grid that isn't a power of two square still has every cell once
+			columns, rows := 5, 3
+			positions = parseConfig.ScanPositions(columns, rows)
+			if len(positions) != columns*rows {
+				t.Fatalf("expected %d positions, got %d", columns*rows, len(positions))
+			}
+			seen := map[image.Point]bool{}
+			for _, position := range positions {
+				if position.X < 0 || position.X >= columns || position.Y < 0 || position.Y >= rows {
+					t.Errorf("position %v is outside the grid", position)
+				}
+				if seen[position] {
+					t.Errorf("position %v is scanned twice", position)
+				}
+				seen[position] = true
+			}
+		})
+	}
+}
+
+func TestParseTolerance(t *testing.T) {
+
+	img := i.Open("../fixtures/test_02.png", false)
+
+	// Nudge one channel of every pixel to simulate lossy compression
+	noisy := image.NewNRGBA(img.Bounds())
+	copy(noisy.Pix, img.Pix)
+	for j := 0; j < len(noisy.Pix); j += 4 {
+		if noisy.Pix[j] < 128 {
+			noisy.Pix[j] += 3
+		} else {
+			noisy.Pix[j] -= 3
+		}
+	}
+
+	exactConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize}
+	_, exact, _, _ := parse([]*image.NRGBA{img, noisy}, nil, exactConfig, nil, false)
+	if len(exact) != 2*9 {
+		t.Fatalf("expected %d unique tiles without tolerance, got %d", 2*9, len(exact))
+	}
+
+	fuzzyConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize, Tolerance: 3}
+	_, fuzzy, _, _ := parse([]*image.NRGBA{img, noisy}, nil, fuzzyConfig, nil, false)
+	if len(fuzzy) != 9 {
+		t.Fatalf("expected %d unique tiles with tolerance, got %d", 9, len(fuzzy))
+	}
+	for index, frequencyTile := range fuzzy {
+		if frequencyTile.MaxError != 3 {
+			t.Errorf("tile %d: expected max error 3, got %.2f", index, frequencyTile.MaxError)
+		}
+	}
+}
+
+func TestParseSkip(t *testing.T) {
+
+	img := i.Open("../fixtures/test_01.png", false)
+
+	// Add a transparent column and a solid red column of tiles to the right
+	bounds := img.Bounds()
+	padded := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()+2*testTileSize, bounds.Dy()))
+	draw.Draw(padded, bounds, img, bounds.Min, draw.Src)
+	red := color.NRGBA{R: 255, A: 255}
+	redColumn := image.Rect(bounds.Dx()+testTileSize, 0, bounds.Dx()+2*testTileSize, bounds.Dy())
+	draw.Draw(padded, redColumn, image.NewUniform(red), image.Point{}, draw.Src)
+
+	parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize, SkipEmpty: true, SkipColor: red}
+	_, frequencyTiles, tileMaps, _ := parse([]*image.NRGBA{padded}, nil, parseConfig, nil, false)
+	if len(frequencyTiles) != 3 {
+		t.Errorf("expected %d unique tiles, got %d", 3, len(frequencyTiles))
+	}
+	tileMap := tileMaps[0]
+	for row := 0; row < tileMap.Rows; row++ {
+		for column := tileMap.Columns - 2; column < tileMap.Columns; column++ {
+			if cell := tileMap.Cell(column, row); cell.Index != -1 {
+				t.Errorf("cell %d,%d: expected to be empty, got index %d", column, row, cell.Index)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var renderCmd *cobra.Command
+
+const validMapExtensionsMessage = "Valid extensions are: \"csv\", \"tmx\" and \"tmj\" (or \"json\")."
+
+func readMap(filename string, tileWidth, tileHeight int) (i.TileMap, error) {
+	extension := strings.ToLower(filepath.Ext(filename))
+	switch extension {
+	case ".csv":
+		flagsFilename := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".flags.csv"
+		return i.ReadIndexCSV(filename, flagsFilename, tileWidth, tileHeight)
+	case ".tmx":
+		return i.ReadTMX(filename)
+	case ".tmj", ".json":
+		return i.ReadTMJ(filename)
+	}
+	return i.TileMap{}, fmt.Errorf("unsupported map extension %q. %s", extension, validMapExtensionsMessage)
+}
+
+func init() {
+
+	renderCmd = &cobra.Command{
+		Use:   "render <tileset> <map>",
+		Short: "Render an image from a tileset and a map.",
+		Long:  "The render command composites the image described by a map of tileset indices, drawing each cell with its tileset tile flipped and rotated as the map requires. The tileset is read with the size, margin and spacing flags. Maps can be csv (with an optional flags.csv sidecar), Tiled tmx or Tiled tmj, as written by the parse command. Empty cells are filled with the background color.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				fmt.Fprintln(os.Stderr, "Two args required: <tileset> <map>")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool render --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			tilesetFilename := args[0]
+			tileMapFilename := args[1]
+
+			img := i.Open(tilesetFilename, Verbose)
+			if err := tc.ReadImage(img); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading tileset: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			if Verbose {
+				fmt.Printf("Opening %s\n", tileMapFilename)
+			}
+			tileMap, err := readMap(tileMapFilename, tc.TileWidth, tc.TileHeight)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading map: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if tileMap.TileWidth != tc.TileWidth || tileMap.TileHeight != tc.TileHeight {
+				fmt.Fprintf(os.Stderr, "Error: map tile size %dx%d doesn't match tileset tile size %dx%d\n",
+					tileMap.TileWidth, tileMap.TileHeight, tc.TileWidth, tc.TileHeight)
+				os.Exit(1)
+			}
+
+			if Verbose {
+				fmt.Printf("Rendering %dx%d map with %d tileset tiles\n", tileMap.Columns, tileMap.Rows, len(tc.TileImages))
+			}
+
+			rendered, err := tileMap.Render(&tc, BgColor)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error rendering map: %s\n", err.Error())
+				os.Exit(1)
+			}
+			i.Save(rendered, Output, Verbose)
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestRenderRoundTrip(t *testing.T) {
+
+	filenames := []string{"../fixtures/test_01.png", "../fixtures/test_02.png", "../fixtures/test_03.png"}
+	extensions := []string{".tmx", ".tmj", ".csv"}
+
+	for _, filename := range filenames {
+		t.Run(filename, func(t *testing.T) {
+			parseConfig := i.ParseConfig{TileWidth: testTileSize, TileHeight: testTileSize}
+			img := i.Open(filename, false)
+			_, frequencyTiles, tileMaps, _ := parse([]*image.NRGBA{img}, nil, parseConfig, CreateTransformations(), false)
+			tileMap := tileMaps[0]
+
+			tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+			for _, frequencyTile := range frequencyTiles {
+				tileset.TileImages = append(tileset.TileImages, frequencyTile.Image)
+			}
+
+			dir := t.TempDir()
+			for _, extension := range extensions {
+				mapFilename := filepath.Join(dir, "map"+extension)
+				var err error
+				switch extension {
+				case ".tmx":
+					err = tileMap.WriteTMX(mapFilename, filepath.Join(dir, "tileset.tsx"))
+				case ".tmj":
+					err = tileMap.WriteTMJ(mapFilename, filepath.Join(dir, "tileset.tsj"))
+				case ".csv":
+					if err = tileMap.WriteIndexCSV(mapFilename); err == nil {
+						err = tileMap.WriteFlagsCSV(filepath.Join(dir, "map.flags.csv"))
+					}
+				}
+				if err != nil {
+					t.Fatalf("%s: error writing map: %s", extension, err.Error())
+				}
+
+				readTileMap, err := readMap(mapFilename, testTileSize, testTileSize)
+				if err != nil {
+					t.Fatalf("%s: error reading map: %s", extension, err.Error())
+				}
+				rendered, err := readTileMap.Render(&tileset, color.Transparent)
+				if err != nil {
+					t.Fatalf("%s: error rendering map: %s", extension, err.Error())
+				}
+				if hashNrgba(rendered) != hashNrgba(img) {
+					t.Errorf("%s: rendered image is different than the parsed image", extension)
+				}
+			}
+		})
+	}
+}
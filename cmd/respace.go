@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"image"
+	"image/color"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -13,13 +15,32 @@ var respaceCmd *cobra.Command
 
 var outMargin int
 var outSpacing int
+var extrusion int
+var metadataFormat string
+
+// respaceTiles lays out the tiles of a tileset with a new margin and spacing.
+// Extruded tiles are read and written with their extrusion, which takes up
+// part of the margin and spacing, so the returned tileset has tiles that size.
+func respaceTiles(img *image.NRGBA, tileset i.TilesetConfig, extrusion, margin, spacing int, bgColor color.Color) i.TilesetConfig {
+	outTc := tileset
+	outTc.TileWidth += 2 * extrusion
+	outTc.TileHeight += 2 * extrusion
+	outTc.Margin = margin - extrusion
+	outTc.Spacing = spacing - 2*extrusion
+	outTc.Color = bgColor
+	outTc.TileImages = make([]*image.NRGBA, len(tileset.TileImages))
+	for j, tileImage := range tileset.TileImages {
+		outTc.TileImages[j] = img.SubImage(tileImage.Bounds().Inset(-extrusion)).(*image.NRGBA)
+	}
+	return outTc
+}
 
 func init() {
 
 	respaceCmd = &cobra.Command{
 		Use:   "respace <filename>",
 		Short: "Respace a tileset.",
-		Long:  "The respace command outputs the tileset with the background replaced and the specified margin and spacing. NOTE: this command replaces the background, so it will remove tile extrusions.",
+		Long:  "The respace command outputs the tileset with the background replaced and the specified margin and spacing. NOTE: this command replaces the background, so it will remove tile extrusions unless the extrusion thickness is given. With an extrusion, the tiles are read and written with that many pixels around them, and the extrusion counts towards the input and output margin and spacing as it does for the extrude command.",
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) != 1 {
 				fmt.Fprintln(os.Stderr, "One arg required: <filename>")
@@ -37,28 +58,60 @@ func init() {
 				fmt.Fprintf(os.Stderr, "Invalid out-spacing: %s\n", err.Error())
 				os.Exit(1)
 			}
+			if err := i.ValidatePixelValue(extrusion); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid extrusion: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if outMargin < extrusion || outSpacing < 2*extrusion {
+				fmt.Fprintf(os.Stderr, "Invalid extrusion: out-margin must be at least %d and out-spacing at least %d to hold it\n", extrusion, 2*extrusion)
+				os.Exit(1)
+			}
+			if err := i.ValidateChoice(metadataFormat, metadataFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid metadata: %s\n", err.Error())
+				os.Exit(1)
+			}
+			validateSheetLayout()
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			filename := args[0]
 
 			img := i.Open(filename, Verbose)
-			tc.ReadImage(img)
+			applyAutoLayout(img)
+			if err := tc.ReadImage(img); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading tileset: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			if tc.Margin < extrusion || tc.Spacing < 2*extrusion {
+				fmt.Fprintf(os.Stderr, "Invalid extrusion: margin must be at least %d and spacing at least %d to hold it\n", extrusion, 2*extrusion)
+				os.Exit(1)
+			}
+
+			outTc := respaceTiles(img, tc, extrusion, outMargin, outSpacing, BgColor)
+			reflow(&outTc, Verbose)
 
-			outTc := tc
-			outTc.Margin = outMargin
-			outTc.Spacing = outSpacing
-			outTc.Color = BgColor
+			metaTc := extrusionMetadata(outTc, tc.TileWidth, tc.TileHeight, extrusion)
 
 			if Verbose {
-				fmt.Printf("Margin: reading: %d writing: %d\n", tc.Margin, outTc.Margin)
-				fmt.Printf("Spacing: reading: %d writing: %d\n", tc.Spacing, outTc.Spacing)
+				fmt.Printf("Margin: reading: %d writing: %d\n", tc.Margin, metaTc.Margin)
+				fmt.Printf("Spacing: reading: %d writing: %d\n", tc.Spacing, metaTc.Spacing)
+				if extrusion > 0 {
+					fmt.Printf("Extrusion: %d\n", extrusion)
+				}
 				fmt.Printf("Background Color: writing: %s\n", BgColorHex)
 			}
 
 			tilesetImage := outTc.ToImage()
 			i.Save(tilesetImage, Output, Verbose)
+
+			if metadataFormat != "none" {
+				writeTilesetMetadata(metadataFormat, metaTc, Verbose)
+			}
 		},
 	}
 	respaceCmd.Flags().IntVar(&outMargin, "out-margin", 0, "output tileset margin in pixels (default 0)")
 	respaceCmd.Flags().IntVar(&outSpacing, "out-spacing", 0, "output tile spacing in pixels (defualt 0)")
+	respaceCmd.Flags().IntVar(&extrusion, "extrusion", 0, "thickness in pixels of the extrusion around the input tiles to keep. The extrusion is part of both the input and output margin and spacing (default 0)")
+	respaceCmd.Flags().StringVar(&metadataFormat, "metadata", "none", fmt.Sprintf("tileset metadata format to write alongside the tileset. %s", validMetadataFormatsMessage))
+	addSheetLayoutFlags(respaceCmd)
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestRespaceExtrusion(t *testing.T) {
+
+	// Extrude the tiles by 2 into a tileset with a margin of 2 and spacing of 4
+	thickness := 2
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	tileset.ReadImage(i.Open("../fixtures/test_02.png", false))
+	extruded := i.NewTilesetConfig(testTileSize+2*thickness, testTileSize+2*thickness, 0, 0, color.Transparent)
+	extruded.Columns = tileset.Columns
+	extrudedTiles := []*image.NRGBA{}
+	for _, tileImage := range tileset.TileImages {
+		extrudedTile := extrudeTile(tileImage, thickness, "wrap", color.Transparent)
+		extrudedTiles = append(extrudedTiles, extrudedTile)
+		extruded.TileImages = append(extruded.TileImages, extrudedTile)
+	}
+	img := extruded.ToImage()
+
+	in := i.NewTilesetConfig(testTileSize, testTileSize, thickness, 2*thickness, color.Transparent)
+	if err := in.ReadImage(img); err != nil {
+		t.Fatal(err)
+	}
+	outTc := respaceTiles(img, in, thickness, 3, 7, color.Transparent)
+	respaced := outTc.ToImage()
+
+	// Each tile keeps its extrusion at its new place
+	out := i.NewTilesetConfig(testTileSize, testTileSize, 3, 7, color.Transparent)
+	out.Columns = outTc.Columns
+	if err := out.ReadImage(respaced); err != nil {
+		t.Fatal(err)
+	}
+	for j, tileImage := range out.TileImages {
+		rect := tileImage.Bounds().Inset(-thickness)
+		got := respaced.SubImage(rect).(*image.NRGBA)
+		if hashNrgba(got) != hashNrgba(extrudedTiles[j]) {
+			t.Errorf("tile %d: the extrusion at %v is different than the extruded tile", j, rect)
+		}
+	}
+}
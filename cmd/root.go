@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"image/color"
 	"os"
+	"strconv"
 
 	"github.com/spf13/cobra"
 
@@ -13,15 +15,38 @@ import (
 var Verbose bool
 var Output string
 
-var tileSize int
+var tileSize string
+var autoSize bool
+var tileWidth int
+var tileHeight int
+var marginValue string
+var spacingValue string
 var margin int
 var spacing int
+var autoMargin bool
+var autoSpacing bool
 var BgColorHex string
 
 var BgColor color.Color
 
 var tc i.TilesetConfig
 
+// parseLayoutValue parses a margin or spacing flag, which is either a pixel
+// value or auto for the commands that can detect it from the tileset
+func parseLayoutValue(value string, cmd *cobra.Command) (int, bool, error) {
+	if value == "auto" {
+		if cmd != respaceCmd && cmd != extrudeCmd && cmd != sliceCmd && cmd != dedupeCmd {
+			return 0, false, errors.New("auto is only supported by respace, extrude, slice and dedupe")
+		}
+		return 0, true, nil
+	}
+	pixels, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("%q is not a whole number of pixels or auto", value)
+	}
+	return pixels, false, i.ValidatePixelValue(pixels)
+}
+
 var rootCmd = &cobra.Command{
 	Use:               "tiletool",
 	Short:             "Command line interface utility for tilesets",
@@ -38,20 +63,52 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		if err := i.ValidatePositivePixelValue(tileSize); err != nil {
-			fmt.Fprintf(os.Stderr, "Invalid size: %s\n", err.Error())
+		var width, height int
+		autoSize = tileSize == "auto"
+		if autoSize {
+			if cmd != parseCmd {
+				fmt.Fprintln(os.Stderr, "Invalid size: auto is only supported by parse")
+				os.Exit(1)
+			}
+			if cmd.Flags().Changed("tile-width") || cmd.Flags().Changed("tile-height") {
+				fmt.Fprintln(os.Stderr, "Invalid size: auto detects the tile size, so tile-width and tile-height can't be used with it")
+				os.Exit(1)
+			}
+			// Parse replaces these once it has detected the grid
+			width, height = defaultDetectSizes[0], defaultDetectSizes[0]
+		} else {
+			width, height, err = i.ParseTileSize(tileSize)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid size: %s\n", err.Error())
+				os.Exit(1)
+			}
+		}
+		if cmd.Flags().Changed("tile-width") {
+			width = tileWidth
+		}
+		if cmd.Flags().Changed("tile-height") {
+			height = tileHeight
+		}
+		if err := i.ValidatePositivePixelValue(width); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid tile-width: %s\n", err.Error())
 			os.Exit(1)
 		}
-		if err := i.ValidatePixelValue(margin); err != nil {
+		if err := i.ValidatePositivePixelValue(height); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid tile-height: %s\n", err.Error())
+			os.Exit(1)
+		}
+		margin, autoMargin, err = parseLayoutValue(marginValue, cmd)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Invalid margin: %s\n", err.Error())
 			os.Exit(1)
 		}
-		if err := i.ValidatePixelValue(spacing); err != nil {
+		spacing, autoSpacing, err = parseLayoutValue(spacingValue, cmd)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Invalid spacing: %s\n", err.Error())
 			os.Exit(1)
 		}
 
-		tc = i.NewTilesetConfig(tileSize, margin, spacing, BgColor)
+		tc = i.NewTilesetConfig(width, height, margin, spacing, BgColor)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
@@ -64,18 +121,31 @@ func init() {
 
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().StringVarP(&Output, "output", "o", "tileset.png", fmt.Sprintf("file name and format to output to. %s", i.ValidOutputExtensionsMessage))
-	rootCmd.PersistentFlags().IntVarP(&tileSize, "size", "s", 16, "input tile size in pixels. Tiles are square")
-	rootCmd.PersistentFlags().IntVarP(&margin, "margin", "m", 0, "input tileset margin in pixels (default 0)")
-	rootCmd.PersistentFlags().IntVarP(&spacing, "spacing", "p", 0, "input tile spacing in pixels (default 0)")
+	rootCmd.PersistentFlags().StringVarP(&tileSize, "size", "s", "16", "input tile size in pixels, either a single value for square tiles or WxH. Parse also accepts auto to detect the size and offset")
+	rootCmd.PersistentFlags().IntVar(&tileWidth, "tile-width", 0, "input tile width in pixels. Overrides the width from size")
+	rootCmd.PersistentFlags().IntVar(&tileHeight, "tile-height", 0, "input tile height in pixels. Overrides the height from size")
+	rootCmd.PersistentFlags().StringVarP(&marginValue, "margin", "m", "0", "input tileset margin in pixels. Respace, extrude, slice and dedupe also accept auto to detect it from the tileset")
+	rootCmd.PersistentFlags().StringVarP(&spacingValue, "spacing", "p", "0", "input tile spacing in pixels. Respace, extrude, slice and dedupe also accept auto to detect it from the tileset")
 	rootCmd.PersistentFlags().StringVarP(&BgColorHex, "color", "c", "#00000000", "output tileset background color in 8 digit hex format (RGBA)")
+}
 
+func Execute() {
+	// Commands are added here rather than in init, since the init functions of
+	// files sorted after root.go, which create their commands, run after it
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(parseCmd)
 	rootCmd.AddCommand(respaceCmd)
 	rootCmd.AddCommand(extrudeCmd)
-}
+	rootCmd.AddCommand(unextrudeCmd)
+	rootCmd.AddCommand(renderCmd)
+	rootCmd.AddCommand(detectCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(packCmd)
+	rootCmd.AddCommand(sliceCmd)
+	rootCmd.AddCommand(atlasCmd)
+	rootCmd.AddCommand(mergeCmd)
+	rootCmd.AddCommand(dedupeCmd)
 
-func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+// The layout of the output tileset image, for the commands that write one
+var sheetLayout i.SheetLayout
+
+func addSheetLayoutFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&sheetLayout.Columns, "columns", 0, "lay out the output tileset in this many columns (default 0, keep the columns)")
+	cmd.Flags().IntVar(&sheetLayout.Rows, "rows", 0, "lay out the output tileset in this many rows (default 0, keep the columns)")
+	cmd.Flags().IntVar(&sheetLayout.MaxWidth, "max-width", 0, "limit the output tileset to this width in pixels, reducing the columns to fit (default 0, no limit)")
+	cmd.Flags().BoolVar(&sheetLayout.Square, "square", false, "lay out the output tileset in the columns that make the smallest, most square image (default false)")
+	cmd.Flags().BoolVar(&sheetLayout.Pot, "pot", false, "pad the output tileset to power of two dimensions, choosing the columns that waste the least area (default false)")
+	cmd.Flags().IntVar(&sheetLayout.Align, "align", 0, "pad the output tileset to dimensions that are a multiple of this many pixels, choosing the columns that waste the least area (default 0, no padding)")
+}
+
+func validateSheetLayout() {
+	if err := i.ValidatePixelValue(sheetLayout.Columns); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid columns: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if err := i.ValidatePixelValue(sheetLayout.Rows); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid rows: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if err := i.ValidatePixelValue(sheetLayout.MaxWidth); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid max-width: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if err := i.ValidatePixelValue(sheetLayout.Align); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid align: %s\n", err.Error())
+		os.Exit(1)
+	}
+	chosen := 0
+	for _, set := range []bool{sheetLayout.Columns > 0, sheetLayout.Rows > 0, sheetLayout.Square} {
+		if set {
+			chosen++
+		}
+	}
+	if chosen > 1 {
+		fmt.Fprintln(os.Stderr, "Invalid layout: only one of columns, rows and square can be used")
+		os.Exit(1)
+	}
+}
+
+// reflow lays out the tileset before it's written
+func reflow(tileset *i.TilesetConfig, verbose bool) {
+	if err := tileset.Reflow(sheetLayout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error laying out tileset: %s\n", err.Error())
+		os.Exit(1)
+	}
+	width, height := tileset.Dims()
+	if verbose {
+		fmt.Printf("Laying out %d tiles in %d columns and %d rows: %dx%d\n",
+			len(tileset.TileImages), tileset.Columns, tileset.Rows(), width, height)
+	}
+	if tileset.Pot || tileset.Align > 1 {
+		contentWidth, contentHeight := tileset.ContentDims()
+		wasted := (width * height) - (contentWidth * contentHeight)
+		fmt.Printf("Padded tileset from %dx%d to %dx%d, wasting %d pixels (%.1f%%)\n",
+			contentWidth, contentHeight, width, height, wasted, 100*float64(wasted)/float64(width*height))
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestReflow(t *testing.T) {
+
+	tests := []struct {
+		layout        i.SheetLayout
+		columns       int
+		width, height int
+	}{
+		{layout: i.SheetLayout{}, columns: 10, width: 180, height: 36},
+		{layout: i.SheetLayout{Columns: 16}, columns: 16, width: 288, height: 36},
+		{layout: i.SheetLayout{Rows: 3}, columns: 7, width: 126, height: 54},
+		{layout: i.SheetLayout{Square: true}, columns: 4, width: 72, height: 90},
+		{layout: i.SheetLayout{MaxWidth: 70}, columns: 3, width: 54, height: 126},
+		{layout: i.SheetLayout{Square: true, MaxWidth: 52}, columns: 2, width: 36, height: 180},
+		{layout: i.SheetLayout{Pot: true}, columns: 3, width: 64, height: 128},
+		{layout: i.SheetLayout{Columns: 16, Align: 5}, columns: 16, width: 290, height: 40},
+		// The padding has room for 14 columns, which the tiles are laid out in
+		{layout: i.SheetLayout{Columns: 10, Pot: true}, columns: 14, width: 256, height: 64},
+	}
+
+	for _, test := range tests {
+		// 20 tiles of 16x16 with a margin of 1 and spacing of 2
+		tileset := i.NewTilesetConfig(testTileSize, testTileSize, 1, 2, color.Transparent)
+		for j := 0; j < 20; j++ {
+			tileset.TileImages = append(tileset.TileImages, image.NewNRGBA(image.Rect(0, 0, testTileSize, testTileSize)))
+		}
+		if err := tileset.Reflow(test.layout); err != nil {
+			t.Fatalf("%+v: %s", test.layout, err.Error())
+		}
+		if tileset.Columns != test.columns {
+			t.Errorf("%+v: expected %d columns, got %d", test.layout, test.columns, tileset.Columns)
+		}
+		if width, height := tileset.Dims(); width != test.width || height != test.height {
+			t.Errorf("%+v: expected %dx%d, got %dx%d", test.layout, test.width, test.height, width, height)
+		}
+	}
+
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	tileset.TileImages = append(tileset.TileImages, image.NewNRGBA(image.Rect(0, 0, testTileSize, testTileSize)))
+	if err := tileset.Reflow(i.SheetLayout{MaxWidth: testTileSize - 1}); err == nil {
+		t.Errorf("expected an error when a single tile is wider than the max width")
+	}
+}
+
+func TestReflowMetadata(t *testing.T) {
+
+	// 20 tiles of 16x16 without margin or spacing, in 10 columns padded to 256
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	for j := 0; j < 20; j++ {
+		tileset.TileImages = append(tileset.TileImages, image.NewNRGBA(image.Rect(0, 0, testTileSize, testTileSize)))
+	}
+	if err := tileset.Reflow(i.SheetLayout{Columns: 10, Pot: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "tileset.tsx")
+	if err := tileset.WriteTSX(filename, "tileset.png"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tsx struct {
+		TileWidth int `xml:"tilewidth,attr"`
+		Spacing   int `xml:"spacing,attr"`
+		Margin    int `xml:"margin,attr"`
+		Columns   int `xml:"columns,attr"`
+		Image     struct {
+			Width int `xml:"width,attr"`
+		} `xml:"image"`
+	}
+	if err := xml.Unmarshal(content, &tsx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tiled works out the columns from the image width
+	columns := (tsx.Image.Width - (2 * tsx.Margin) + tsx.Spacing) / (tsx.TileWidth + tsx.Spacing)
+	if tsx.Image.Width != 256 || tsx.Columns != columns {
+		t.Errorf("expected a 256 pixel wide image with the %d columns that fit in it, got %d pixels and %d columns",
+			columns, tsx.Image.Width, tsx.Columns)
+	}
+}
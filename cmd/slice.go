@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var sliceCmd *cobra.Command
+
+var nameTemplate string
+
+// Numbers in slice filenames are padded to at least this many digits
+const minSliceDigits = 4
+
+// sliceFilename fills the name template for a tile. Each number is zero
+// padded to the width of the largest value it takes, so that the files sort
+// in order.
+func sliceFilename(template string, index, row, column int, tileset i.TilesetConfig) string {
+	pad := func(v, max int) string {
+		digits := len(strconv.Itoa(max))
+		if digits < minSliceDigits {
+			digits = minSliceDigits
+		}
+		return fmt.Sprintf("%0*d", digits, v)
+	}
+	replacer := strings.NewReplacer(
+		"{index}", pad(index, len(tileset.TileImages)-1),
+		"{row}", pad(row, tileset.Rows()-1),
+		"{column}", pad(column, tileset.Columns-1),
+	)
+	return replacer.Replace(template)
+}
+
+func validateNameTemplate(template string) error {
+	if strings.Contains(template, "{index}") || (strings.Contains(template, "{row}") && strings.Contains(template, "{column}")) {
+		return nil
+	}
+	return fmt.Errorf("template must contain {index}, or both {row} and {column}, to name each tile differently")
+}
+
+func init() {
+
+	sliceCmd = &cobra.Command{
+		Use:   "slice <filename>",
+		Short: "Slice a tileset into tile images.",
+		Long:  "The slice command writes each tile of a tileset to an image of its own. The tileset is read with the size, margin and spacing flags. Files are named with the name template, in which {index} is replaced with the tile's index in the tileset, and {row} and {column} with its place in the tileset, zero padded. Directories in the template are created. Fully transparent tiles, or tiles entirely of the skip color, can be left out.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "One arg required: <filename>")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool slice --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if err := validateNameTemplate(nameTemplate); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid name: %s\n", err.Error())
+				os.Exit(1)
+			}
+			if skipColorHex != "" {
+				if _, err := i.ColorFromHex(skipColorHex); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid skip-color: %s\n", err.Error())
+					os.Exit(1)
+				}
+			}
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filename := args[0]
+
+			img := i.Open(filename, Verbose)
+			applyAutoLayout(img)
+			if err := tc.ReadImage(img); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading tileset: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			parseConfig := i.ParseConfig{SkipEmpty: skipEmpty}
+			if skipColorHex != "" {
+				parseConfig.SkipColor, _ = i.ColorFromHex(skipColorHex)
+			}
+
+			written := 0
+			for index, tileImage := range tc.TileImages {
+				if parseConfig.IsSkipped(tileImage) {
+					continue
+				}
+				tileFilename := sliceFilename(nameTemplate, index, index/tc.Columns, index%tc.Columns, tc)
+				if err := os.MkdirAll(filepath.Dir(tileFilename), 0755); err != nil {
+					fmt.Fprintf(os.Stderr, "Error saving file: %s\n", err.Error())
+					os.Exit(1)
+				}
+				i.Save(tileImage, tileFilename, Verbose)
+				written++
+			}
+			fmt.Printf("Sliced %d tiles, skipped %d\n", written, len(tc.TileImages)-written)
+		},
+	}
+	sliceCmd.Flags().StringVar(&nameTemplate, "name", "out/tile_{index}.png", "template for tile filenames, where {index}, {row} and {column} are replaced with the tile's place in the tileset")
+	sliceCmd.Flags().BoolVar(&skipEmpty, "skip-empty", false, "don't write fully transparent tiles (default false)")
+	sliceCmd.Flags().StringVar(&skipColorHex, "skip-color", "", "don't write tiles entirely of this color, in 8 digit hex format (RGBA)")
+}
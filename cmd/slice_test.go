@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestSliceFilename(t *testing.T) {
+
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	tileset.Columns = 120
+	for j := 0; j < 12345; j++ {
+		tileset.TileImages = append(tileset.TileImages, image.NewNRGBA(image.Rect(0, 0, testTileSize, testTileSize)))
+	}
+
+	tests := map[string]string{
+		"out/tile_{index}.png":            "out/tile_00250.png",
+		"{row}/{column}.png":              "0002/0010.png",
+		"tile_{index}_{row}x{column}.gif": "tile_00250_0002x0010.gif",
+	}
+	for template, expected := range tests {
+		if err := validateNameTemplate(template); err != nil {
+			t.Errorf("%s: %s", template, err.Error())
+		}
+		if got := sliceFilename(template, 250, 2, 10, tileset); got != expected {
+			t.Errorf("%s: expected %s, got %s", template, expected, got)
+		}
+	}
+
+	for _, template := range []string{"tile.png", "tile_{row}.png"} {
+		if err := validateNameTemplate(template); err == nil {
+			t.Errorf("%s: expected an error for a template that names tiles the same", template)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+var unextrudeCmd *cobra.Command
+
+// unextrudeModes are the extrude modes that copy the tile into the extrusion,
+// so that the extrusion can be told apart from the gutters around it
+var unextrudeModes = []string{"clamp", "wrap", "mirror"}
+
+// edgeExtruded checks whether the ring of pixels distance pixels outside a
+// tile copies the tile the way the extrude mode does. Corners are skipped,
+// since extruders differ in how they fill them. A transparent ring matches a
+// transparent tile whether or not it was extruded, so it isn't evidence of
+// extrusion.
+func edgeExtruded(img *image.NRGBA, tile image.Rectangle, distance int, mode string) (extruded, evidence bool) {
+	first := img.NRGBAAt(tile.Min.X, tile.Min.Y-distance)
+	matches := func(x, y int) bool {
+		c := img.NRGBAAt(x, y)
+		if c != first || c.A != 0 {
+			evidence = true
+		}
+		return c == img.NRGBAAt(
+			extrusionSource(x, tile.Min.X, tile.Max.X, mode),
+			extrusionSource(y, tile.Min.Y, tile.Max.Y, mode))
+	}
+	for x := tile.Min.X; x < tile.Max.X; x++ {
+		if !matches(x, tile.Min.Y-distance) || !matches(x, tile.Max.Y-1+distance) {
+			return false, false
+		}
+	}
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		if !matches(tile.Min.X-distance, y) || !matches(tile.Max.X-1+distance, y) {
+			return false, false
+		}
+	}
+	return true, evidence
+}
+
+// detectExtrusion finds the thickest extrusion that every tile of the tileset
+// has, in any of the unextrude modes. The extrusion has to fit in the margin
+// and in half the spacing.
+func detectExtrusion(img *image.NRGBA, tileset i.TilesetConfig) (thickness int) {
+	limit := tileset.Margin
+	if tileset.Spacing/2 < limit {
+		limit = tileset.Spacing / 2
+	}
+	for _, mode := range unextrudeModes {
+		modeThickness := 0
+		for distance := 1; distance <= limit; distance++ {
+			extruded, evidence := true, false
+			for _, tileImage := range tileset.TileImages {
+				tileExtruded, tileEvidence := edgeExtruded(img, tileImage.Bounds(), distance, mode)
+				if !tileExtruded {
+					extruded = false
+					break
+				}
+				evidence = evidence || tileEvidence
+			}
+			if !extruded || !evidence {
+				break
+			}
+			modeThickness = distance
+		}
+		if modeThickness > thickness {
+			thickness = modeThickness
+		}
+	}
+	return
+}
+
+func init() {
+
+	unextrudeCmd = &cobra.Command{
+		Use:   "unextrude <filename>",
+		Short: "Remove the extrusion from the tiles of a tileset.",
+		Long:  "The unextrude command detects the thickness of the extrusion around the tiles of a tileset, by checking how far the pixels around each tile copy it the way the clamp, wrap or mirror modes of the extrude command do, and outputs the tileset without it. Read the tileset with the original tile size and the margin and spacing that include the extrusion, as reported by the extrude command. The unextruded tileset has the margin reduced by the thickness and the spacing reduced by twice the thickness, with the gutters filled with the background color. Extrusion with the transparent or color modes can't be told apart from the gutters, so it isn't detected.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				fmt.Fprintln(os.Stderr, "One arg required: <filename>")
+				fmt.Fprintln(os.Stderr, "Use \"tiletool unextrude --help\" for more information.")
+				os.Exit(1)
+			}
+			return nil
+		},
+		PreRun: func(cmd *cobra.Command, args []string) {
+			if err := i.ValidateChoice(metadataFormat, metadataFormats); err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid metadata: %s\n", err.Error())
+				os.Exit(1)
+			}
+			validateSheetLayout()
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			filename := args[0]
+
+			img := i.Open(filename, Verbose)
+			if err := tc.ReadImage(img); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading tileset: %s\n", err.Error())
+				os.Exit(1)
+			}
+
+			thickness := detectExtrusion(img, tc)
+			if thickness == 0 {
+				fmt.Fprintln(os.Stderr, "Error: no extrusion detected. Check that the size, margin and spacing include the extrusion in the margin and spacing.")
+				os.Exit(1)
+			}
+
+			outTc := tc
+			outTc.Margin = tc.Margin - thickness
+			outTc.Spacing = tc.Spacing - 2*thickness
+			outTc.Color = BgColor
+
+			reflow(&outTc, Verbose)
+
+			fmt.Printf("Detected extrusion thickness: %d\n", thickness)
+			fmt.Printf("Unextruded tileset has tile size: %dx%d, margin: %d and spacing: %d\n",
+				outTc.TileWidth, outTc.TileHeight, outTc.Margin, outTc.Spacing)
+
+			tilesetImage := outTc.ToImage()
+			i.Save(tilesetImage, Output, Verbose)
+
+			if metadataFormat != "none" {
+				writeTilesetMetadata(metadataFormat, outTc, Verbose)
+			}
+		},
+	}
+	unextrudeCmd.Flags().StringVar(&metadataFormat, "metadata", "none", fmt.Sprintf("tileset metadata format to write alongside the tileset. %s", validMetadataFormatsMessage))
+	addSheetLayoutFlags(unextrudeCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	i "github.com/davidwarshaw/tiletool/cmd/internal"
+)
+
+func TestUnextrude(t *testing.T) {
+
+	original := i.NewTilesetConfig(testTileSize, testTileSize, 0, 0, color.Transparent)
+	original.ReadImage(i.Open("../fixtures/test_02.png", false))
+	original.Margin = 1
+	original.Spacing = 2
+
+	for _, mode := range unextrudeModes {
+		for _, thickness := range []int{1, 2, 3} {
+			extruded := original
+			extruded.TileWidth += 2 * thickness
+			extruded.TileHeight += 2 * thickness
+			extruded.TileImages = make([]*image.NRGBA, len(original.TileImages))
+			for j, tileImage := range original.TileImages {
+				extruded.TileImages[j] = extrudeTile(tileImage, thickness, mode, color.Transparent)
+			}
+			img := extruded.ToImage()
+
+			// Read the extruded tileset the way Tiled sees it
+			tileset := i.NewTilesetConfig(testTileSize, testTileSize, original.Margin+thickness, original.Spacing+2*thickness, color.Transparent)
+			if err := tileset.ReadImage(img); err != nil {
+				t.Fatalf("%s thickness %d: %s", mode, thickness, err.Error())
+			}
+			if detected := detectExtrusion(img, tileset); detected != thickness {
+				t.Errorf("%s: expected thickness %d, got %d", mode, thickness, detected)
+			}
+			for j, tileImage := range tileset.TileImages {
+				if hashNrgba(tileImage) != hashNrgba(original.TileImages[j]) {
+					t.Errorf("%s thickness %d: tile %d doesn't match the original", mode, thickness, j)
+				}
+			}
+		}
+	}
+
+	img := original.ToImage()
+	tileset := i.NewTilesetConfig(testTileSize, testTileSize, original.Margin, original.Spacing, color.Transparent)
+	tileset.ReadImage(img)
+	if detected := detectExtrusion(img, tileset); detected != 0 {
+		t.Errorf("expected no extrusion on the original tileset, got %d", detected)
+	}
+}